@@ -1,7 +1,7 @@
 // Package assets prepares CSS and JS files for development and production. It reads,
 // processes, and joins asset sources and emits final .css and .js files. "Process"
-// means converting LESS and CoffeeScript files into CSS and JS, and compressing
-// final files.
+// means converting LESS, Sass, Stylus, CoffeeScript, TypeScript, and JSX files into
+// CSS and JS, and compressing final files.
 //
 // API is very simple:
 //
@@ -23,32 +23,78 @@
 // generated files.
 // 
 // Compilation and compression of assets are performed by external tools "coffee",
-// "lessc", and "yuicompressor", so you should have these tools installed and in your
-// PATH if you want to use these features.
+// "tsc", "esbuild", "lessc", "sassc", "stylus", and "yuicompressor", so you should
+// have these tools installed and in your PATH if you want to use these features.
+// Tools that are not in PATH can be configured through Commands.
 package assets
 
 import (
-	"crypto/md5"
+	"bytes"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
-	ErrNoInput = errors.New("assets: no input file given")
-	ErrMix     = errors.New("assets: can't mix CSS and JS in one asset")
+	ErrNoInput     = errors.New("assets: no input file given")
+	ErrMix         = errors.New("assets: can't mix CSS and JS in one asset")
+	ErrCompressMap = errors.New("assets: can't make source maps of compressed assets")
+	ErrNotBuilt    = errors.New("assets: asset is not built yet")
+	ErrName        = errors.New(`assets: name can't have path separators or be ".."`)
 )
 
+// defaultSeparator is put between inputs when they're joined, unless another one is
+// set by SetSeparator. Without it, a JS file that doesn't end in a newline or
+// semicolon could be merged with the first statement of the next file.
+var defaultSeparator = []byte("\n")
+
 // type input holds content of each asset source.
 type input struct {
 	bytes []byte
 	// extension of the source file is all the information we need, besides the
 	// content of the file
 	ext string
+	// name of the source file, or empty for inputs that are added from memory
+	fname string
+	// source map of the compiled input, if any
+	sourceMap []byte
+	// inputs that are joined into this one, if any
+	parts []input
+}
+
+// String returns name of the input file, or a label like "<bytes:css>" for inputs
+// that are added from memory.
+func (inp input) String() string {
+	if len(inp.fname) > 0 {
+		return inp.fname
+	}
+	return "<bytes:" + strings.TrimPrefix(inp.ext, ".") + ">"
+}
+
+// Logger is the interface of loggers that can be passed to SetLogger. *log.Logger
+// implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
 }
 
 // type Asset holds a group of assets. You add your asset sources to an Asset, and it
@@ -59,328 +105,2876 @@ type input struct {
 //
 // Each Asset emits a single .css or .js file. Mixing CSS and JS in one Asset gives an
 // error.
+//
+// An Asset should not be used by more than one goroutine at a time, but different
+// assets can be put in the same directory at the same time; changes of each directory,
+// including its manifest, are made by one of them at a time.
 type Asset struct {
-	filenames       []string // names of the input files
-	inputs          []input  // contents of the input files
-	hashes          []string // MD5 hash of each input file
-	bytes           []byte   // content of output file
-	dir, name       string   // dir and name of the asset, passed arguments of Put
-	ext             string   // extension, either ".css" or ".js"
-	fname, oldfname string   // name of final file
-	compress        bool     // does it need compression?
-	join            bool     // should join LESS and CoffeeScript before compiling?
+	sources         []input                                     // added file names, or contents added from memory
+	ignores         []string                                    // patterns of file names that are not inputs
+	strictGlobs     bool                                        // should fail for globs that match no files?
+	inputs          []input                                     // contents of the input files
+	hashes          []string                                    // hash of each input file
+	bytes           []byte                                      // content of output file
+	dir, name       string                                      // dir and name of the asset, passed arguments of Put
+	ext             string                                      // extension, either ".css" or ".js"
+	fname, oldfname string                                      // name of final file
+	sidecars        []string                                    // names of files emitted next to final file
+	oldsidecars     []string                                    // names of files emitted next to old final file
+	files, oldfiles []string                                    // names of files of inputs, written in dev mode
+	devFiles        []devFile                                   // files of inputs, made in dev mode
+	sourceMap       []byte                                      // source map of final file
+	cached          bool                                        // was final file unchanged in the last Put?
+	dryRun          bool                                        // is the build for DryRun, which doesn't write anything?
+	changed         []string                                    // inputs that caused the last Put to rebuild
+	resolved        []string                                    // names of inputs, after expanding globs
+	compressCSS     bool                                        // does CSS need compression?
+	compressJS      bool                                        // does JS need compression?
+	joinCSS         bool                                        // should join LESS, Sass, and Stylus before compiling?
+	joinJS          bool                                        // should join CoffeeScript, TypeScript, and JSX before compiling?
+	babel           bool                                        // should pass JS through Babel before compression?
+	dev             bool                                        // should write inputs as separate files, without compression?
+	cssCompressor   []string                                    // command and arguments of CSS compressor
+	jsCompressor    []string                                    // command and arguments of JS compressor
+	hashName        string                                      // name of the hash algorithm
+	hashLength      int                                         // length of hash in file name, 0 means full length
+	fsys            fs.FS                                       // filesystem of input files, nil means the OS's
+	sourceMaps      bool                                        // should emit source maps?
+	manifest        bool                                        // should add final file to manifest?
+	gzip            bool                                        // should write gzipped copy of final file?
+	brotli          bool                                        // should write Brotli compressed copy of final file?
+	logger          Logger                                      // logger of build steps, nil means no logging
+	concurrency     int                                         // maximum number of inputs compiled at once
+	keepOld         bool                                        // should keep old final file on rebuild?
+	banner          string                                      // text of comment on top of final file
+	fileMode        os.FileMode                                 // permissions of written files
+	dirMode         os.FileMode                                 // permissions of created output directory
+	infoDir         string                                      // directory of info file, empty means output directory
+	inputStamps     []stamp                                     // stamps of inputs, made before reading them
+	depStamps       []stamp                                     // stamps of files imported by inputs
+	license         bool                                        // should keep /*! comments when compressing?
+	nameTemplate    string                                      // text/template of name of final file
+	mapFiles        map[string]string                           // source map files of inputs, keyed by the inputs
+	compileCache    string                                      // directory of cached compiler outputs
+	toolArgs        map[string][]string                         // extra arguments of external tools
+	sassStyle       string                                      // output style of Sass compiler, or empty for default
+	coffeeBare      bool                                        // should compile CoffeeScript without the wrapper function?
+	joinMode        string                                      // how CoffeeScript files are joined
+	licenseFile     bool                                        // should write license comments to a file?
+	licenses        []byte                                      // license comments of inputs, for the license file
+	priorities      map[string]int                              // priorities of inputs, keyed by names or their suffixes
+	ignoreFile      string                                      // name of file of ignore patterns
+	compiled        []CompiledInput                             // inputs after compilation, in the last build
+	cssLevel        int                                         // how aggressive CSS compression is, from 0 to 2
+	versionDir      string                                      // directory of output files, in the output directory
+	baseDir         string                                      // directory of relative file names and globs
+	emitDebug       bool                                        // should write uncompressed output next to final file?
+	debugBytes      []byte                                      // uncompressed output, for the debug file
+	createDir       bool                                        // should create output directory if it doesn't exist?
+	oldsum          string                                      // SHA-256 of old final file, from info file
+	toolEnv         []string                                    // "name=value" of environment variables of tools, sorted
+	progress        func(step, detail string)                   // called when each step of Put starts
+	transform       func(ext string, in []byte) ([]byte, error) // changes content of inputs before compiling
+	served          atomic.Value                                // *served, what handlers of the asset serve
+	memo            *memo                                       // what the last Put did, nil if it failed
+	postBuild       func(fname, fullpath string) error          // called after Put writes a new final file
+	fnameExt        string                                      // extension of final file, empty means ext
+	urlBase         [2]string                                   // directories that url() of CSS is moved from and to
+	allowBinary     bool                                        // should read inputs that look binary?
+	latest          bool                                        // should keep a file with a stable name for final file?
+	latestSymlink   bool                                        // should latest file be a symlink, rather than a copy?
+	oldlatest       string                                      // name of latest file, from info file
+	fingerprint     bool                                        // should put hash of content in name of final file?
+	inlineMax       int                                         // maximum size of files inlined in CSS, 0 means none
+	imports         bool                                        // should replace @import rules of CSS with the files?
+	autoprefix      bool                                        // should pass CSS through autoprefixer?
+	dedup           bool                                        // should drop inputs that repeat earlier ones?
+	separator       []byte                                      // put between inputs when joining them
+	timeout         time.Duration                               // maximum run time of each external tool, 0 means none
+	variables       []string                                    // "name=value" of LESS and Sass variables, sorted
 }
 
 // New makes an Asset and adds given filenames to it. You can tweak the returned
 // asset by adding more files, or just ask it to emit final file by calling Put.
 func New(filenames ...string) *Asset {
-	a := &Asset{compress: true, join: true}
+	a := &Asset{compressCSS: true, compressJS: true, joinCSS: true, joinJS: true,
+		cssCompressor: cssCompressors["yuicompressor"],
+		jsCompressor:  jsCompressors["yuicompressor"],
+		hashName:      "md5",
+		fingerprint:   true,
+		separator:     defaultSeparator,
+		joinMode:      JoinSource,
+		cssLevel:      2,
+		createDir:     true,
+		nameTemplate:  defaultNameTemplate,
+		fileMode:      0666,
+		dirMode:       0755}
 	a.Add(filenames...)
 	return a
 }
 
-// Add appends filenames to the Asset a.
+// Add appends filenames to the Asset a. Besides file names and globs, filenames can
+// be HTTP or HTTPS URLs, which are fetched on each build, so that changes of the
+// remote files are noticed. Extension of a URL is taken from its path, e.g.,
+// "https://cdn.example.com/lib.min.js?v=2" is a ".js" input. See HTTPTimeout.
 func (a *Asset) Add(filenames ...string) {
-	a.filenames = append(a.filenames, filenames...)
+	for _, filename := range filenames {
+		a.sources = append(a.sources, input{fname: filename})
+	}
 }
 
-// Put produces final asset file, puts it in dir, and returns its name. Name of the
-// file includes the name that's passed as second argument, MD5 hash of the content of
-// of the file, and its extention, which is either ".css" or ".js". You can omit the
-// name by passing an empty string for it.
-func (a *Asset) Put(dir, name string) (fname string, err error) {
-	a.dir = dir
-	a.name = name
-	// expand globs
-	if err = a.expandGlobs(); err != nil {
-		return
+// Ignore makes the Asset skip the input files that match any of patterns, even if
+// they match the added file names and globs, e.g., to skip "*.min.js" files that are
+// matched by "*.js". Patterns without a slash, like "*.min.js", are matched against
+// the base names of the files, and the others, like "js/vendor/**", against the
+// whole names, with the same syntax as globs.
+func (a *Asset) Ignore(patterns ...string) {
+	a.ignores = append(a.ignores, patterns...)
+}
+
+// SetPriority changes order of the inputs, which is the order they're added in, and
+// the alphabetical order for matches of each glob. Inputs are sorted by their
+// priorities, from low to high, and inputs with the same priority keep their order.
+// Priorities are keyed by names of the files, or their endings after a slash, so
+// "jquery.js" matches "js/lib/jquery.js". If more than one key matches a file, the
+// longest one wins. Files without a priority have priority 0, so
+//
+//         a.SetPriority(map[string]int{"jquery.js": -1})
+//
+// puts jquery.js before all the other inputs. priorities is copied, so it's safe to
+// modify it after the call.
+func (a *Asset) SetPriority(priorities map[string]int) {
+	a.priorities = make(map[string]int)
+	for name, p := range priorities {
+		a.priorities[filepath.ToSlash(name)] = p
 	}
-	// check for zero input files
-	if len(a.filenames) == 0 {
-		return "", ErrNoInput
+}
+
+// priority returns priority of input file fname. See SetPriority.
+func (a *Asset) priority(fname string) int {
+	fname = filepath.ToSlash(fname)
+	p, longest := 0, -1
+	for name, q := range a.priorities {
+		if len(name) > longest && (fname == name || strings.HasSuffix(fname, "/"+name)) {
+			p, longest = q, len(name)
+		}
 	}
-	// read files into inputs
-	if err = a.readInputs(); err != nil {
-		return
+	return p
+}
+
+// SetIgnoreFile makes the Asset read ignore patterns from file fname, in addition to
+// the ones passed to Ignore, so that exclusions can be kept in one place, like in a
+// .gitignore file. The file has one pattern per line, and lines that are empty or
+// start with "#" are skipped. Patterns have the same syntax as the ones passed to
+// Ignore, but the ones with a slash are relative to the directory of the file. The
+// file is read on each Put, so changes to it are noticed.
+func (a *Asset) SetIgnoreFile(fname string) {
+	a.ignoreFile = fname
+}
+
+// SetBaseDir makes the Asset resolve relative file names and globs of inputs, and
+// patterns with a slash that are passed to Ignore, against dir, instead of the
+// working directory, so that they don't have to repeat it. Absolute names are not
+// changed. Names of the inputs, like the ones Inputs returns, include dir.
+func (a *Asset) SetBaseDir(dir string) {
+	a.baseDir = dir
+}
+
+// resolve returns file name or pattern name resolved against the base directory of
+// a.
+func (a *Asset) resolve(name string) string {
+	if len(a.baseDir) == 0 {
+		return name
 	}
-	// now we know if asset is either ".css" or ".js"
-	a.ext = a.inputs[0].ext
-	switch a.ext {
-	case ".coffee":
-		a.ext = ".js"
-	case ".less":
-		a.ext = ".css"
+	if a.fsys != nil {
+		// names of io/fs are never rooted
+		return path.Join(a.baseDir, name)
 	}
-	if a.ext != ".css" && a.ext != ".js" {
-		errMsg := "assets: unsupported extension \"" + a.ext + "\""
-		return "", errors.New(errMsg)
+	if filepath.IsAbs(name) {
+		return name
 	}
-	// join LESS and CoffeeScript files before making any progress
-	if a.join {
-		a.joinFiles()
+	return filepath.Join(a.baseDir, name)
+}
+
+// SetAllowBinary makes the Asset read inputs that look like binary files, rather than
+// text, which are rejected by default, since they're usually matched by globs by
+// mistake, like images or .DS_Store files.
+func (a *Asset) SetAllowBinary(allow bool) {
+	a.allowBinary = allow
+}
+
+// SetStrictGlobs makes the Asset fail if any of the added file names and globs
+// doesn't match any file, instead of ignoring it. It's useful for catching typos in
+// file names. It is disabled by default.
+func (a *Asset) SetStrictGlobs(strict bool) {
+	a.strictGlobs = strict
+}
+
+// AddReader reads all the content of r and appends it to the Asset a, as if it was a
+// file with extension ext, like ".css" or ".less". Order of the inputs is preserved,
+// so content of r is placed after the files that are added before it.
+func (a *Asset) AddReader(r io.Reader, ext string) error {
+	bytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
 	}
-	// read hashes of inputs
-	if err = a.makeHashes(); err != nil {
+	a.sources = append(a.sources, input{bytes: bytes, ext: ext})
+	return nil
+}
+
+// AddString appends content to the Asset a, as if it was a file with extension ext,
+// like ".css" or ".coffee". It's joined, compiled, and checked for changes just like
+// the files. Since it has no file name, it's not subject to glob expansion and is
+// always placed right after the sources added before it.
+func (a *Asset) AddString(content, ext string) {
+	a.sources = append(a.sources, input{bytes: []byte(content), ext: ext})
+}
+
+// AddBytes is like AddString, but takes content as a byte slice. content is copied,
+// so it's safe to modify it after the call.
+func (a *Asset) AddBytes(content []byte, ext string) {
+	b := append([]byte(nil), content...)
+	a.sources = append(a.sources, input{bytes: b, ext: ext})
+}
+
+// Put produces final asset file, puts it in dir, and returns its name. Name of the
+// file includes the name that's passed as second argument, hash of the content of the
+// file (MD5 by default, see SetHash), and its extention, which is either ".css" or
+// ".js". You can omit the name by passing an empty string for it.
+func (a *Asset) Put(dir, name string) (fname string, err error) {
+	if err = checkName(name); err != nil {
 		return
 	}
-	// read old info and check if anything has changed
-	if changed, err := a.checkSavedInfo(); err != nil || !changed {
-		return a.oldfname, err
+	if !a.createDir {
+		if err = checkDir(dir); err != nil {
+			return
+		}
 	}
-	// things have changed. delete old files before starting to work
-	if err = a.deleteOld(); err != nil {
+	a.dir = dir
+	a.name = name
+	if err = a.prepare(); err != nil {
 		return
 	}
-	// compile LESS and CoffeeSCript
-	if err = a.compile(); err != nil {
+	// check if anything has changed, by what a remembers from its last Put in this
+	// process, or else by the old info
+	recalled, err := a.recall()
+	if err != nil {
 		return
 	}
-	// check extensions of all the inputs
-	for _, input := range a.inputs {
-		if input.ext != a.ext {
-			return "", ErrMix
+	changed := !recalled
+	if !recalled {
+		a.memo = nil
+		if changed, err = a.checkSavedInfo(true); err != nil {
+			return
 		}
 	}
-	// join inputs
-	for _, input := range a.inputs {
-		a.bytes = append(a.bytes, input.bytes...)
-	}
-	// compress
-	if a.compress {
-		switch a.ext {
-		case ".css":
-			a.bytes, err = runCSSCompress(a.bytes)
-			if err != nil {
-				return
-			}
-		case ".js":
-			a.bytes, err = runJSCompress(a.bytes)
-			if err != nil {
-				return
-			}
+	if !changed {
+		a.fname, a.sidecars, a.cached = a.oldfname, a.oldsidecars, true
+		a.files = a.oldfiles
+		a.step("cache-hit", a.fname)
+		a.logf("%s is unchanged", a.fname)
+		defer lockDir(dir)()
+		if err = a.writeManifest(); err != nil {
+			return
 		}
+		a.remember()
+		a.publish()
+		return a.fname, nil
 	}
-	// make filename
-	sum, err := hash(a.bytes)
+	// things have changed. delete old files before starting to work
+	unlock := lockDir(dir)
+	err = a.deleteOld()
+	unlock()
 	if err != nil {
 		return
 	}
-	if len(a.name) > 0 {
-		a.fname = name + "-"
-	}
-	a.fname += sum + a.ext
-	// create output directory if it does not exists
-	if err = os.MkdirAll(dir, 0755); err != nil {
+	if err = a.build(); err != nil {
 		return
 	}
-	// save to output file
-	err = ioutil.WriteFile(path.Join(dir, a.fname), a.bytes, 0666)
-	if err != nil {
+	a.step("write", a.fname)
+	if err = a.write(); err != nil {
 		return
 	}
-	// save asset info files
-	if err = a.saveInfo(); err != nil {
-		return
+	a.logf("wrote %s", path.Join(dir, a.fname))
+	a.publish()
+	// the hook may take long, so it's run after the output directory is unlocked
+	if a.postBuild != nil {
+		if err = a.postBuild(a.fname, path.Join(dir, a.fname)); err != nil {
+			return "", fmt.Errorf("assets: post-build hook: %w", err)
+		}
 	}
+	a.remember()
 
 	return a.fname, nil
 }
 
-// SetCompress enables or disables output compression by yuicompressor. It is enable
-// by default. Call SetCompress(false) to disable.
-func (a *Asset) SetCompress(compress bool) {
-	a.compress = compress
-}
-
-// SetJoin can change behaviour of Asset in handling multiple LESS and CoffeeScript
-// files. By default, if multiple .less or mulitple .coffee files are provided, Asset
-// joins them into a single one before compiling them into CSS and JavaScript. This is
-// useful for separating LESS and CoffeeScript code into multiple files. You can
-// disable this behavior by setting Join to false.
-//
-// Please note that Asset should preserve order of input files, so if you provide it
-// with
-//
-//         a.Add("a.coffee", "b.js", "c.coffee", "d.coffee")
-//
-// only third and fourth files are joined before compilation.
-func (a *Asset) SetJoin(join bool) {
-	a.join = join
+// PutAll does what Put does for each of dirs, but builds the asset only once. The
+// final file is put in the first directory, and it's copied with its sidecars to the
+// others. Each directory keeps its own info file, so files of the previous build are
+// deleted from each of them, and directories that are up to date are not touched.
+// Names of the final file are returned, keyed by the directories. Since each
+// directory needs its own info file, SetInfoDir can't be used with more than one
+// directory. The hook of SetPostBuild is only called for the first directory.
+func (a *Asset) PutAll(dirs []string, name string) (map[string]string, error) {
+	if len(dirs) == 0 {
+		return nil, errors.New("assets: no output directory")
+	}
+	if len(a.infoDir) > 0 && len(dirs) > 1 {
+		return nil, errors.New("assets: info directory can't be shared by output directories")
+	}
+	if !a.createDir {
+		for _, dir := range dirs {
+			if err := checkDir(dir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fname, err := a.Put(dirs[0], name)
+	if err != nil {
+		return nil, err
+	}
+	fnames := map[string]string{dirs[0]: fname}
+	for _, dir := range dirs[1:] {
+		// copying a directory to itself would delete its files
+		if _, ok := fnames[dir]; ok || path.Clean(dir) == path.Clean(dirs[0]) {
+			fnames[dir] = fname
+			continue
+		}
+		if err = a.mirror(dir); err != nil {
+			return nil, err
+		}
+		fnames[dir] = fname
+	}
+	return fnames, nil
 }
 
-// expandGlobs replaces globs in filenames with real file names
-func (a *Asset) expandGlobs() error {
-	var l []string
-	for _, filename := range a.filenames {
-		matches, err := filepath.Glob(filename)
+// mirror copies the final file that a has put in its output directory, with its
+// sidecars and info file, to dir, after deleting files of the previous build in dir.
+// Nothing is done if dir already has the same info file and files.
+func (a *Asset) mirror(dir string) error {
+	buf, err := ioutil.ReadFile(a.infoPath())
+	if err != nil {
+		return err
+	}
+	// the asset is about its own output directory, so dir is handled by another one
+	m := a.placed(dir, a.name)
+	defer lockDir(dir)()
+	if _, err = m.readInfo(); err != nil {
+		return err
+	}
+	fnames := append([]string{a.fname}, a.sidecars...)
+	if old, err := ioutil.ReadFile(m.infoPath()); err == nil && bytes.Equal(old, buf) &&
+		allExist(dir, fnames) {
+		a.logf("%s is unchanged", path.Join(dir, a.fname))
+		return m.writeManifest()
+	}
+	if err = m.deleteOld(); err != nil {
+		return err
+	}
+	for _, fname := range fnames {
+		b, err := ioutil.ReadFile(path.Join(a.dir, fname))
 		if err != nil {
 			return err
 		}
-		l = append(l, matches...)
+		if err = os.MkdirAll(path.Dir(path.Join(dir, fname)), a.dirMode); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(path.Join(dir, fname), b, a.fileMode); err != nil {
+			return err
+		}
+	}
+	if a.latest {
+		if err = m.writeLatest(); err != nil {
+			return err
+		}
+	}
+	if err = ioutil.WriteFile(m.infoPath(), buf, a.fileMode); err != nil {
+		return err
 	}
-	a.filenames = l
+	if err = m.writeManifest(); err != nil {
+		return err
+	}
+	a.logf("wrote %s", path.Join(dir, a.fname))
 	return nil
 }
 
-// readInputs loads input files into inputs variable of a.
-func (a *Asset) readInputs() error {
-	for _, filename := range a.filenames {
-		ext := path.Ext(filename)
-		bytes, err := ioutil.ReadFile(filename)
-		if err != nil {
-			return err
+// placed returns an Asset with only the settings of a that decide names and places of
+// its files, and its final file and sidecars, for handling its files in dir, with the
+// given name, without changing a. Nothing else, like state of the builds of a, is
+// shared with it.
+func (a *Asset) placed(dir, name string) *Asset {
+	return &Asset{
+		dir:           dir,
+		name:          name,
+		ext:           a.ext,
+		fnameExt:      a.fnameExt,
+		infoDir:       a.infoDir,
+		fname:         a.fname,
+		sidecars:      append([]string{}, a.sidecars...),
+		keepOld:       a.keepOld,
+		latest:        a.latest,
+		latestSymlink: a.latestSymlink,
+		manifest:      a.manifest,
+		fileMode:      a.fileMode,
+		dirMode:       a.dirMode,
+		logger:        a.logger,
+	}
+}
+
+// allExist reports whether all the files fnames exist in dir.
+func allExist(dir string, fnames []string) bool {
+	for _, fname := range fnames {
+		if _, err := os.Stat(path.Join(dir, fname)); err != nil {
+			return false
 		}
-		a.inputs = append(a.inputs, input{ext: ext, bytes: bytes})
 	}
-	return nil
+	return true
 }
 
-// joinFiles joins subsequent LESS or CoffeeScript inputs into single ones.
-//
-// To preserve of the input files, only sequential LESS or CoffeScript files are
-// joined as a group. That means that if we have, for example, files "a.coffee",
-// "b.js", "c.coffee", and "d.coffee", only third and fourth files are joined.
-func (a *Asset) joinFiles() {
-	if len(a.inputs) == 0 {
+// write saves the final file of a in its output directory, with its sidecars and info
+// file, and records it in the manifest. Other assets may be writing to the same
+// directory at the same time, so it's locked meanwhile.
+func (a *Asset) write() (err error) {
+	defer lockDir(a.dir)()
+	// create output directory if it does not exists, and the directories in it
+	// that are made by name template
+	if err = os.MkdirAll(path.Dir(path.Join(a.dir, a.fname)), a.dirMode); err != nil {
 		return
 	}
-	// LESS or CoffeeScript?
-	ext := ""
-	switch a.inputs[0].ext {
-	case ".js", ".coffee":
-		ext = ".coffee"
-	case ".css", ".less":
-		ext = ".less"
+	// save to output file
+	err = ioutil.WriteFile(path.Join(a.dir, a.fname), a.bytes, a.fileMode)
+	if err != nil {
+		return
 	}
-	// can't use range because the list will be changed during the loop
-	for i := 0; i < len(a.inputs); i++ {
-		// a keeps content of current group of joinable files, starting
-		// from file at a.inputs[i]
-		bytes := make([]byte, 0)
-		n := 0
-		for j := i; j < len(a.inputs); j++ {
-			if a.inputs[j].ext == ext {
-				bytes = append(bytes, a.inputs[j].bytes...)
-				n++
-			} else {
-				// first non-joinable file ends the sequence
-				break
+	// save source map
+	if a.sourceMap != nil {
+		if err = a.writeSidecar(a.fname+".map", a.sourceMap); err != nil {
+			return
+		}
+	}
+	// save files of inputs, with their source maps
+	for _, f := range a.devFiles {
+		if err = os.MkdirAll(path.Dir(path.Join(a.dir, f.fname)), a.dirMode); err != nil {
+			return
+		}
+		if err = a.writeSidecar(f.fname, f.bytes); err != nil {
+			return
+		}
+		if f.sourceMap != nil {
+			if err = a.writeSidecar(f.fname+".map", f.sourceMap); err != nil {
+				return
 			}
 		}
-		// n == 0 means the current file is not joinable
-		// n == 1 means current file is joinable, but its alone
-		// both of these situations don't need anything to be done
-		if n < 2 {
-			continue
+		a.files = append(a.files, f.fname)
+	}
+	// save uncompressed output
+	if a.debugBytes != nil {
+		dfname := strings.TrimSuffix(a.fname, a.fileExt()) + ".debug" + a.fileExt()
+		if err = a.writeSidecar(dfname, a.debugBytes); err != nil {
+			return
 		}
-
-		// join all the files
-		a.inputs[i].bytes = bytes
-		// delete subsequent joined files
-		a.inputs = append(a.inputs[:i+1], a.inputs[i+n:]...)
 	}
-}
-
-// makeHashes generates MD5 hashes of inputs.
-func (a *Asset) makeHashes() error {
-	for _, inp := range a.inputs {
-		sum, err := hash(inp.bytes)
+	// save license comments
+	if a.licenses != nil {
+		lfname := strings.TrimSuffix(a.fname, a.fileExt()) + ".LICENSE.txt"
+		if err = a.writeSidecar(lfname, a.licenses); err != nil {
+			return
+		}
+	}
+	// save precompressed copies
+	if a.gzip {
+		b, err := gzipBytes(a.bytes)
 		if err != nil {
 			return err
 		}
-		a.hashes = append(a.hashes, sum)
+		if err = a.writeSidecar(a.fname+".gz", b); err != nil {
+			return err
+		}
 	}
-	return nil
-}
-
-// checkSavedInfo loads asset-info file and see if anything has changed or not
-func (a *Asset) checkSavedInfo() (chnaged bool, err error) {
-	buf, err := ioutil.ReadFile(path.Join(a.dir, a.infoFname()))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return true, nil
-		} else {
+	if a.brotli {
+		b, err := brotliBytes(a.bytes)
+		if err != nil {
+			return err
+		}
+		if err = a.writeSidecar(a.fname+".br", b); err != nil {
+			return err
+		}
+	}
+	// point the latest file to the new final file
+	if a.latest {
+		if err = a.writeLatest(); err != nil {
 			return
 		}
 	}
-	lines := strings.Split(string(buf), "\n")
-	if len(lines) < 2 {
-		return true, nil
+	// save asset info files
+	if err = a.saveInfo(); err != nil {
+		return
 	}
-	a.oldfname = lines[0]
-	lines = lines[1:]
-	if len(lines) != len(a.hashes) {
-		return true, nil
+	return a.writeManifest()
+}
+
+// DryRun does everything Put does, except that it doesn't touch the disk other than
+// for reading input files and the info file. It returns the name that Put would
+// return, and whether Put would build the asset again. It's useful for knowing the
+// name of the final file ahead of time, like for warming up a CDN before deploying.
+func (a *Asset) DryRun(dir, name string) (fname string, changed bool, err error) {
+	if err = checkName(name); err != nil {
+		return
 	}
-	for i, line := range lines {
-		if a.hashes[i] != line {
-			return true, nil
-		}
+	a.dir, a.name = dir, name
+	if err = a.prepare(); err != nil {
+		return
 	}
-	return false, nil
+	// compiler outputs are not saved in the compile cache either
+	a.dryRun = true
+	defer func() { a.dryRun = false }()
+	if changed, err = a.checkSavedInfo(false); err != nil {
+		return
+	}
+	if !changed {
+		a.fname, a.sidecars, a.cached = a.oldfname, a.oldsidecars, true
+		a.files = a.oldfiles
+		return a.fname, false, nil
+	}
+	if err = a.build(); err != nil {
+		return
+	}
+	return a.fname, true, nil
 }
 
-// deleteOld deletes old asset file and asset info file. This is called before
-// generating new file, to keep output directory clean.
-func (a *Asset) deleteOld() error {
-	if len(a.oldfname) > 0 {
-		err := os.Remove(path.Join(a.dir, a.oldfname))
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
+// Verify checks that the final file that Put put in dir, with the given name, is not
+// changed since then, like by tampering or a partial write. SHA-256 of the file is
+// compared with the one saved in the info file, and an error describes any mismatch.
+// Inputs are not read, but their globs are expanded to find out the extension of the
+// final file. The Asset itself is left as it is, so Verify can be called between its
+// Puts.
+func (a *Asset) Verify(dir, name string) error {
+	if err := checkName(name); err != nil {
+		return err
 	}
-	err := os.Remove(path.Join(a.dir, a.infoFname()))
-	if err != nil && !os.IsNotExist(err) {
+	// globs are expanded without keeping the inputs in a
+	inputs, err := a.globInputs()
+	if err != nil {
 		return err
 	}
-	return nil
-}
-
-// compile converts LESS and CoffeeScript inputs to CSS and JS, respectively.
-func (a *Asset) compile() error {
-	for i := 0; i < len(a.inputs); i++ {
-		switch a.inputs[i].ext {
-		case ".less":
-			b, err := runLess(a.inputs[i].bytes)
-			if err != nil {
-				return err
-			}
-			a.inputs[i].bytes = b
-			a.inputs[i].ext = ".css"
-		case ".coffee":
-			b, err := runCoffee(a.inputs[i].bytes)
-			if err != nil {
-				return err
-			}
-			a.inputs[i].bytes = b
-			a.inputs[i].ext = ".js"
-		}
+	if len(inputs) == 0 {
+		return ErrNoInput
+	}
+	ext, err := outputExt(inputs)
+	if err != nil {
+		return err
+	}
+	v := a.placed(dir, name)
+	v.ext = ext
+	buf, err := ioutil.ReadFile(v.infoPath())
+	if err != nil {
+		return err
+	}
+	var inf info
+	if err = json.Unmarshal(buf, &inf); err != nil || len(inf.SHA256) == 0 {
+		return fmt.Errorf("assets: info file %s has no digest of the final file", v.infoPath())
+	}
+	f, err := os.Open(path.Join(dir, inf.Fname))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sum, err := hashReader(f, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	if sum != inf.SHA256 {
+		return fmt.Errorf("assets: %s is changed since it was put; its SHA-256 is %s, "+
+			"but %s is expected", path.Join(dir, inf.Fname), sum, inf.SHA256)
 	}
 	return nil
 }
 
-// saveInfo stores output file name and hashes in info file.
-func (a *Asset) saveInfo() error {
-	output := a.fname + "\n" + strings.Join(a.hashes, "\n")
-	err := ioutil.WriteFile(path.Join(a.dir, a.infoFname()), []byte(output), 0666)
+// checkDir returns an error if dir is not an existing directory.
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("assets: output directory %q doesn't exist", dir)
+	}
 	if err != nil {
 		return err
 	}
+	if !info.IsDir() {
+		return fmt.Errorf("assets: output directory %q is not a directory", dir)
+	}
 	return nil
 }
 
-// infoFname returns name of info file for asset.
-func (a *Asset) infoFname() string {
-	if len(a.name) > 0 {
-		return "asset-info-" + a.name + "-" + a.ext[1:]
+// checkName returns ErrName if name of an asset could make files outside of the
+// output directory.
+func checkName(name string) error {
+	if strings.ContainsAny(name, `/\`) || name == ".." {
+		return ErrName
 	}
-	return "asset-info-" + a.ext[1:]
+	return nil
 }
 
-// hash returns MD5 hash of r.
-func hash(b []byte) (sum string, err error) {
-	h := md5.New()
-	if _, err = h.Write(b); err != nil {
+// Build does everything Put does, except that it doesn't touch the disk other than
+// for reading input files. It returns content of the final asset file and the name
+// that Put would have given to it. It's useful for serving assets from memory, or
+// in environments with read-only filesystems.
+//
+// Since nothing is saved, Build doesn't have any info about the previous builds and
+// always processes the inputs.
+func (a *Asset) Build(name string) (b []byte, fname string, err error) {
+	a.dir, a.name = "", name
+	if err = a.prepare(); err != nil {
+		return
+	}
+	if err = a.load(); err != nil {
+		return
+	}
+	if err = a.build(); err != nil {
+		return
+	}
+	a.publish()
+	return a.bytes, a.fname, nil
+}
+
+// Clean removes the files that Put has made for an asset with the given name and
+// extension, ".css" or ".js", or the one set by SetOutputExt, in dir: the final file,
+// its sidecars, and the info file.
+// It's useful when an asset is renamed or not used anymore. It doesn't do anything if
+// the files don't exist. Info files in other directories, set by SetInfoDir, are not
+// found by Clean.
+func Clean(dir, name, ext string) error {
+	if !extRe.MatchString(ext) {
+		return errors.New("assets: unsupported extension \"" + ext + "\"")
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+	a := &Asset{dir: dir, name: name, ext: ext}
+	if _, err := a.readInfo(); err != nil {
+		return err
+	}
+	return a.deleteOld()
+}
+
+// OutputPath returns path of the final file made by the last call to Put, which is
+// the name returned by Put joined with the directory passed to it, or an empty string
+// if the asset is not put yet.
+func (a *Asset) OutputPath() string {
+	if len(a.fname) == 0 || len(a.dir) == 0 {
+		return ""
+	}
+	return path.Join(a.dir, a.fname)
+}
+
+// Integrity returns the Subresource Integrity value of the final file made by the
+// last call to Put or Build, which is the base64-encoded SHA-384 digest of the file,
+// prefixed with "sha384-". It's suitable for the integrity attribute of script and
+// link elements.
+func (a *Asset) Integrity() (string, error) {
+	b, err := a.output()
+	if err != nil {
+		return "", err
+	}
+	sum := sha512.Sum384(b)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// Tag returns the HTML tag that loads the final file made by the last call to Put or
+// Build: a script element for JS, or a link element for CSS. Address of the file is
+// urlPrefix, like "/static", followed by the name of the file. The tag has the
+// integrity attribute too, so that it always matches the file. See Integrity.
+func (a *Asset) Tag(urlPrefix string) (template.HTML, error) {
+	integrity, err := a.Integrity()
+	if err != nil {
+		return "", err
+	}
+	src := template.HTMLEscapeString(strings.TrimSuffix(urlPrefix, "/") + "/" + a.fname)
+	if a.ext == ".css" {
+		return template.HTML(`<link rel="stylesheet" href="` + src + `" integrity="` +
+			integrity + `">`), nil
+	}
+	return template.HTML(`<script src="` + src + `" integrity="` + integrity +
+		`"></script>`), nil
+}
+
+// Bytes returns content of the final file made by the last call to Put or Build, or
+// nil if the asset is not built yet. It's useful for uploading the file somewhere
+// else, without reading it back from disk.
+func (a *Asset) Bytes() []byte {
+	b, err := a.output()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// CompiledInput is an input of an asset after compilation. See CompiledInputs.
+type CompiledInput struct {
+	Name  string // name of the input, like Inputs returns
+	Ext   string // extension of the input before compilation, like ".less"
+	Bytes []byte // compiled code of the input
+}
+
+// CompiledInputs returns the inputs of the last call to Put or Build right after they
+// were compiled, before they're processed further and joined, so that they can be
+// inspected one by one. Inputs that don't need compilation are returned as they are,
+// and inputs that are joined before compilation are returned as one, named after the
+// first of them. It returns nil if the asset is not built yet, or Put found it
+// unchanged. Bytes of the inputs should not be modified.
+func (a *Asset) CompiledInputs() []CompiledInput {
+	return a.compiled
+}
+
+// Inputs returns names of the inputs of the last call to Put or Build, in order,
+// after globs are expanded and ignored files are dropped. Inputs that are added from
+// memory are represented by labels like "<bytes:css>". It's useful for finding out
+// which files a broad glob brings in. It returns nil if the asset is not put yet.
+func (a *Asset) Inputs() []string {
+	return a.resolved
+}
+
+// ChangedInputs returns why the last call to Put built the asset again: names of the
+// input files whose contents have changed since the previous Put, and "<bytes:ext>"
+// for the changed inputs that are added from memory. Inputs that are joined before
+// compilation are reported by the first one of them. Changes that can't be blamed on
+// a single input are reported by these labels:
+//
+//         <info>     there's no usable info file, like in the first Put
+//         <count>    inputs are added or removed
+//         <options>  options that change the final file have changed
+//
+// It returns nil if the last Put found the asset unchanged, or it's not put yet.
+func (a *Asset) ChangedInputs() []string {
+	return a.changed
+}
+
+// ContentHash returns hash of the content of the final file made by the last call to
+// Put or Build, made by the hash algorithm of the Asset, or an empty string if the
+// asset is not built yet. It's the same hash that's used in the name of the file,
+// unless the name has a shorter hash, or the file links to a source map.
+func (a *Asset) ContentHash() string {
+	// unchanged final file is streamed from disk
+	if a.cached {
+		f, err := os.Open(path.Join(a.dir, a.fname))
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		sum, err := hashReader(f, hashFuncs[a.hashName])
+		if err != nil {
+			return ""
+		}
+		return sum
+	}
+	b, err := a.output()
+	if err != nil {
+		return ""
+	}
+	sum, err := hash(b, hashFuncs[a.hashName])
+	if err != nil {
+		return ""
+	}
+	return sum
+}
+
+// output returns content of the final file made by the last call to Put or Build.
+// If Put found the asset unchanged, content is read from the output directory.
+func (a *Asset) output() ([]byte, error) {
+	if a.cached {
+		return ioutil.ReadFile(path.Join(a.dir, a.fname))
+	}
+	if len(a.fname) == 0 {
+		return nil, ErrNotBuilt
+	}
+	return a.bytes, nil
+}
+
+// prepare finds the inputs of a and checks their extensions, without reading them.
+func (a *Asset) prepare() error {
+	// forget about any previous build
+	a.inputs, a.hashes, a.bytes, a.sourceMap = nil, nil, nil, nil
+	a.licenses, a.compiled, a.debugBytes = nil, nil, nil
+	a.inputStamps, a.depStamps = nil, nil
+	a.fname, a.oldfname, a.oldsum, a.oldlatest = "", "", "", ""
+	a.sidecars, a.oldsidecars = nil, nil
+	a.files, a.oldfiles, a.devFiles = nil, nil, nil
+	a.cached, a.changed, a.resolved = false, nil, nil
+	// expand globs
+	a.step("expand", "")
+	if err := a.expandGlobs(); err != nil {
+		return err
+	}
+	for _, inp := range a.inputs {
+		a.resolved = append(a.resolved, inp.String())
+	}
+	// check for zero input files
+	if len(a.inputs) == 0 {
+		return ErrNoInput
+	}
+	// find out if asset is either ".css" or ".js", before doing any work
+	var err error
+	a.ext, err = outputExt(a.inputs)
+	return err
+}
+
+// outputExt returns extension of the output that is made from inputs, which is
+// either ".css" or ".js". It returns ErrMix if inputs make both.
+func outputExt(inputs []input) (string, error) {
+	var outExt string
+	for _, inp := range inputs {
+		ext := family(inp.ext)
+		if len(ext) == 0 {
+			errMsg := "assets: unsupported extension \"" + inp.ext + "\""
+			return "", errors.New(errMsg)
+		}
+		if len(outExt) == 0 {
+			outExt = ext
+		} else if ext != outExt {
+			return "", ErrMix
+		}
+	}
+	return outExt, nil
+}
+
+// Validate checks the inputs of the Asset without reading or building them. Globs are
+// expanded, and extensions of the inputs are checked, so that errors like ErrMix,
+// ErrNoInput, and unsupported extensions can be caught before a build, e.g., by
+// linters.
+func (a *Asset) Validate() error {
+	if err := a.expandGlobs(); err != nil {
+		return err
+	}
+	if len(a.inputs) == 0 {
+		return ErrNoInput
+	}
+	_, err := outputExt(a.inputs)
+	return err
+}
+
+// load reads inputs and computes their hashes, which is all that's needed to decide
+// whether the asset needs to be built again.
+func (a *Asset) load() error {
+	// read files into inputs
+	a.step("read", "")
+	if err := a.readInputs(); err != nil {
+		return err
+	}
+	// imported files are part of the inputs, so that their changes are noticed
+	if a.imports {
+		if err := a.inlineImports(); err != nil {
+			return err
+		}
+	}
+	// inputs are hashed and compiled as they're transformed
+	if a.transform != nil {
+		if err := a.transformInputs(); err != nil {
+			return err
+		}
+	}
+	// drop repeated inputs, before they're joined with others
+	if a.dedup {
+		if err := a.dedupInputs(); err != nil {
+			return err
+		}
+	}
+	// join files that need compilation before making any progress
+	a.joinFiles()
+	// read hashes of inputs
+	return a.makeHashes()
+}
+
+// transformInputs passes content of each input through the transform function of a.
+func (a *Asset) transformInputs() error {
+	for i, inp := range a.inputs {
+		b, err := a.transform(inp.ext, inp.bytes)
+		if err != nil {
+			return fmt.Errorf("assets: can't transform %s: %w", inp, err)
+		}
+		a.inputs[i].bytes = b
+	}
+	return nil
+}
+
+// build compiles, joins, and compresses prepared inputs into bytes of a, and names
+// the final file.
+func (a *Asset) build() (err error) {
+	if a.makesMap() && a.compressed() {
+		return ErrCompressMap
+	}
+	// compile LESS and CoffeeSCript
+	if err = a.compile(); err != nil {
+		return
+	}
+	// check extensions of all the inputs; JS modules are still JS
+	for _, input := range a.inputs {
+		if family(input.ext) != a.ext {
+			return ErrMix
+		}
+	}
+	// inline small files referenced by CSS
+	if a.inlineMax > 0 && a.ext == ".css" {
+		if err = a.inlineURLs(); err != nil {
+			return
+		}
+	}
+	// rewrite url() of CSS for the output directory
+	if len(a.urlBase[0]) > 0 && a.ext == ".css" {
+		a.rebaseURLs()
+	}
+	// transpile modern JS
+	if a.babel && a.ext == ".js" {
+		if err = a.transpile(); err != nil {
+			return
+		}
+	}
+	// add vendor prefixes to CSS
+	if a.autoprefix && a.ext == ".css" {
+		if err = a.prefix(); err != nil {
+			return
+		}
+	}
+	// make files of inputs, before they're joined
+	if a.dev {
+		if err = a.makeDevFiles(); err != nil {
+			return
+		}
+	}
+	// join inputs
+	a.step("join", "")
+	for i, input := range a.inputs {
+		if i > 0 {
+			a.bytes = append(a.bytes, a.separator...)
+		}
+		a.bytes = append(a.bytes, input.bytes...)
+	}
+	// collect licenses for the license file
+	if a.licenseFile {
+		a.licenses, _ = extractLicenses(a.bytes)
+	}
+	// keep uncompressed output for the debug file
+	if a.emitDebug {
+		a.debugBytes = a.bytes
+	}
+	// compress
+	if a.compressed() {
+		if a.ext == ".css" {
+			a.step("compress", a.cssCompressor[0])
+		} else {
+			a.step("compress", a.jsCompressor[0])
+		}
+		var licenses []byte
+		if a.license {
+			licenses, a.bytes = extractLicenses(a.bytes)
+		}
+		switch a.ext {
+		case ".css":
+			a.bytes, err = a.runCSSCompress(a.bytes)
+			if err != nil {
+				return
+			}
+		case ".js":
+			a.bytes, err = a.runJSCompress(a.bytes)
+			if err != nil {
+				return
+			}
+		}
+		a.bytes = append(licenses, a.bytes...)
+	}
+	// prepend banner, after compression so that it's not stripped
+	var prefix []byte
+	if len(a.banner) > 0 {
+		prefix = makeBanner(a.banner, now())
+		a.bytes = append(prefix, a.bytes...)
+		if a.debugBytes != nil {
+			a.debugBytes = append(prefix[:len(prefix):len(prefix)], a.debugBytes...)
+		}
+	}
+	// make filename
+	sum, err := hash(a.bytes, hashFuncs[a.hashName])
+	if err != nil {
+		return
+	}
+	if a.hashLength > 0 && a.hashLength < len(sum) {
+		sum = sum[:a.hashLength]
+	}
+	if !a.fingerprint {
+		a.fname = a.name + a.fileExt()
+	} else if a.fname, err = makeName(a.nameTemplate, nameData{a.name, sum, a.fileExt()}); err != nil {
+		return
+	}
+	a.fname = path.Join(a.versionDir, a.fname)
+	// make source map, and link to it at the end of the output
+	if a.makesMap() {
+		a.sourceMap, err = joinSourceMaps(a.inputs, prefix, a.separator, path.Base(a.fname))
+		if err != nil {
+			return
+		}
+		if a.sourceMap != nil {
+			a.bytes = append(a.bytes, sourceMapComment(a.ext, path.Base(a.fname)+".map")...)
+		}
+	}
+	return nil
+}
+
+// SetCompress enables or disables output compression by yuicompressor. It is enable
+// by default. Call SetCompress(false) to disable. It changes compression of both CSS
+// and JS; see SetCompressCSS and SetCompressJS to change one of them.
+func (a *Asset) SetCompress(compress bool) {
+	a.compressCSS, a.compressJS = compress, compress
+}
+
+// SetCompressCSS enables or disables compression of CSS output. It is enabled by
+// default.
+func (a *Asset) SetCompressCSS(compress bool) {
+	a.compressCSS = compress
+}
+
+// SetCompressJS enables or disables compression of JS output. It is enabled by
+// default.
+func (a *Asset) SetCompressJS(compress bool) {
+	a.compressJS = compress
+}
+
+// compressed reports whether output of a is compressed, based on its extension.
+func (a *Asset) compressed() bool {
+	if a.dev {
+		return false
+	}
+	if a.ext == ".css" {
+		return a.compressCSS && !a.sassCompresses()
+	}
+	return a.compressJS
+}
+
+// sassCompresses reports whether the Sass compiler compresses all of the CSS of a,
+// which is when its output style is "compressed", and all the inputs are Sass, by
+// their extensions before compilation.
+func (a *Asset) sassCompresses() bool {
+	if a.sassStyle != "compressed" || len(a.inputs) == 0 {
+		return false
+	}
+	var exts []string
+	if len(a.compiled) > 0 {
+		for _, c := range a.compiled {
+			exts = append(exts, c.Ext)
+		}
+	} else {
+		for _, inp := range a.inputs {
+			exts = append(exts, inp.ext)
+		}
+	}
+	for _, ext := range exts {
+		if ext != ".scss" && ext != ".sass" {
+			return false
+		}
+	}
+	return true
+}
+
+// SetDev enables or disables dev mode, which is meant for development, where fast
+// rebuilds and readable files matter more than small ones. It is disabled by
+// default. In dev mode, inputs are neither joined before compilation, nor compressed,
+// regardless of SetJoin and SetCompress, and Put writes each compiled input as its own
+// file too, next to the final file. Names of these files are made like the name of
+// the final file, from the asset name and name of the input, like "app-menu", and
+// they get their own source maps if source maps are enabled. See Files.
+func (a *Asset) SetDev(dev bool) {
+	a.dev = dev
+}
+
+// Files returns names of the files of the inputs written by the last call to Put in
+// dev mode, in the order of the inputs, so that they can be loaded one by one instead
+// of the final file. It returns nil if dev mode is disabled. See SetDev.
+func (a *Asset) Files() []string {
+	return a.files
+}
+
+// devFile is the file of an input, made in dev mode.
+type devFile struct {
+	fname     string
+	bytes     []byte
+	sourceMap []byte
+}
+
+// makeDevFiles makes a file of each input of a, named after the asset and the input.
+// Inputs that are added from memory or URLs are named after their position.
+func (a *Asset) makeDevFiles() error {
+	for i, inp := range a.inputs {
+		name := "input" + strconv.Itoa(i+1)
+		if len(inp.fname) > 0 && !isURL(inp.fname) {
+			name = filepath.Base(inp.fname)
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		if len(a.name) > 0 {
+			name = a.name + "-" + name
+		}
+		f := devFile{bytes: inp.bytes}
+		sum, err := hash(f.bytes, hashFuncs[a.hashName])
+		if err != nil {
+			return err
+		}
+		if a.hashLength > 0 && a.hashLength < len(sum) {
+			sum = sum[:a.hashLength]
+		}
+		if !a.fingerprint {
+			f.fname = name + a.fileExt()
+		} else if f.fname, err = makeName(a.nameTemplate, nameData{name, sum, a.fileExt()}); err != nil {
+			return err
+		}
+		f.fname = path.Join(a.versionDir, f.fname)
+		if inp.sourceMap != nil && a.makesMap() {
+			f.sourceMap = inp.sourceMap
+			f.bytes = append(f.bytes[:len(f.bytes):len(f.bytes)],
+				sourceMapComment(a.ext, path.Base(f.fname)+".map")...)
+		}
+		a.devFiles = append(a.devFiles, f)
+	}
+	return nil
+}
+
+// SetSourceMaps enables or disables emitting source maps for the compiled LESS and
+// CoffeeScript inputs. It is disabled by default. When enabled, Put writes the source
+// map next to the final file, with the same name plus a ".map" extension, and links
+// to it at the end of the final file.
+//
+// Source maps don't survive compression, so you should disable compression to use
+// them. Otherwise Put returns ErrCompressMap.
+func (a *Asset) SetSourceMaps(sourceMaps bool) {
+	a.sourceMaps = sourceMaps
+}
+
+// SetMapFiles sets the source map files of inputs that are compiled before they're
+// added, like JS files compiled by tsc, keyed by names of the inputs. Put combines
+// them into the source map of the final file, like the source maps that are made by
+// SetSourceMaps, and changes of the map files are noticed like changes of the
+// inputs. Links to the map files at the end of the inputs are removed. Like other
+// source maps, they don't survive compression, so you should disable compression to
+// use them. maps is copied, so it's safe to modify it after the call.
+func (a *Asset) SetMapFiles(maps map[string]string) {
+	a.mapFiles = make(map[string]string)
+	for fname, mapFname := range maps {
+		a.mapFiles[filepath.Clean(fname)] = mapFname
+	}
+}
+
+// makesMap reports whether a makes a source map for the final file.
+func (a *Asset) makesMap() bool {
+	return a.sourceMaps || len(a.mapFiles) > 0
+}
+
+// SourceMap returns the source map made by the last call to Put or Build, or nil if
+// no source map was made. It's useful for serving the source map of an asset that's
+// built in memory, which is expected to be available next to the asset, with the same
+// name plus a ".map" extension.
+func (a *Asset) SourceMap() []byte {
+	return a.sourceMap
+}
+
+// SetGzip enables or disables writing a gzipped copy of the final file next to it,
+// with the same name plus a ".gz" extension. It is disabled by default. It's useful
+// for web servers that can serve precompressed files.
+func (a *Asset) SetGzip(gzip bool) {
+	a.gzip = gzip
+}
+
+// SetBrotli enables or disables writing a Brotli compressed copy of the final file
+// next to it, with the same name plus a ".br" extension. It is disabled by default.
+// It can be used together with SetGzip to have both copies.
+func (a *Asset) SetBrotli(brotli bool) {
+	a.brotli = brotli
+}
+
+// extRe matches extensions that can be given to final files.
+var extRe = regexp.MustCompile(`^\.[A-Za-z0-9]{1,15}$`)
+
+// SetOutputExt changes the extension of the final file, and the files made after it,
+// like ".mjs" instead of ".js", so that servers send the right MIME type for it.
+// Inputs are still compiled and compressed as CSS or JS, as their extensions decide.
+// ext should be a dot followed by letters and digits. Pass an empty string to restore
+// the default, which is ".css" or ".js".
+func (a *Asset) SetOutputExt(ext string) error {
+	if len(ext) > 0 && !extRe.MatchString(ext) {
+		return errors.New("assets: bad output extension \"" + ext + "\"")
+	}
+	a.fnameExt = ext
+	return nil
+}
+
+// fileExt returns the extension of the final file of a.
+func (a *Asset) fileExt() string {
+	if len(a.fnameExt) > 0 {
+		return a.fnameExt
+	}
+	return a.ext
+}
+
+// SetLatestLink enables or disables keeping a file with a stable name next to the
+// final file, which has the name passed to Put plus "-latest", and the extension of
+// the final file, like "app-latest.js", or just "latest.js" for assets without name.
+// It's updated whenever the final file changes, and it's useful for tools that need
+// a predictable path to the newest output. It's a copy of the final file, unless
+// SetLatestSymlink asks for a symlink. It is disabled by default.
+func (a *Asset) SetLatestLink(latest bool) {
+	a.latest = latest
+}
+
+// SetLatestSymlink makes the latest file of SetLatestLink a symlink to the final
+// file, rather than a copy of it. It is disabled by default.
+func (a *Asset) SetLatestSymlink(symlink bool) {
+	a.latestSymlink = symlink
+}
+
+// latestFname returns name of the latest file of a, or an empty string if a doesn't
+// keep one.
+func (a *Asset) latestFname() string {
+	if !a.latest {
+		return ""
+	}
+	if len(a.name) > 0 {
+		return a.name + "-latest" + a.fileExt()
+	}
+	return "latest" + a.fileExt()
+}
+
+// writeLatest makes the latest file of a, as a copy of the final file or a symlink
+// to it. A temporary file is renamed to the latest file, so that it's replaced
+// atomically, and it's never missing or partly written.
+func (a *Asset) writeLatest() error {
+	lfname := path.Join(a.dir, a.latestFname())
+	tmp := lfname + ".tmp"
+	os.Remove(tmp)
+	if a.latestSymlink {
+		// the latest file is right in the output directory, like names of final files
+		if err := os.Symlink(a.fname, tmp); err != nil {
+			return err
+		}
+	} else {
+		b, err := ioutil.ReadFile(path.Join(a.dir, a.fname))
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(tmp, b, a.fileMode); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, lfname); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// SetBanner makes the Asset put text in a comment on top of the final file, which is
+// useful for copyright and license notices. The comment is added after compression,
+// so it's never stripped, or without compression, on top of the joined inputs, which
+// are then left as they are. It's part of the content that makes the name of the
+// final file. Any "{{date}}" in text is replaced with the date of the build, like
+// "2006-01-02". Pass an empty string to remove the banner.
+func (a *Asset) SetBanner(text string) {
+	a.banner = text
+}
+
+// SetConcurrency sets the maximum number of inputs that are compiled at the same
+// time. It's 1 by default, which means inputs are compiled one by one. Since each
+// compilation runs an external tool, compiling many LESS or CoffeeScript files gets
+// much faster with higher values. Order of inputs is preserved either way.
+func (a *Asset) SetConcurrency(n int) {
+	a.concurrency = n
+}
+
+// SetKeepOld makes Put keep the old final file and its sidecars when the asset
+// changes, instead of deleting them. It's useful when pages that refer to the old
+// file may still be served, e.g., during a rolling deploy or behind a CDN. The info
+// file still tracks only the new file, so you are responsible for deleting the old
+// files when they're not needed anymore.
+func (a *Asset) SetKeepOld(keepOld bool) {
+	a.keepOld = keepOld
+}
+
+// SetCreateDir changes whether Put creates the output directory if it doesn't exist.
+// It is enabled by default. Disable it when the directory should be made in advance,
+// e.g., with specific ownership, so that Put returns an error instead of hiding a
+// mistake. Directories in the output directory, like the ones made by name template,
+// are still created.
+func (a *Asset) SetCreateDir(create bool) {
+	a.createDir = create
+}
+
+// SetFileMode changes permissions of the files that Put writes, including the info
+// file and the manifest. By default it is 0666, and like other files, it's subject to
+// umask.
+func (a *Asset) SetFileMode(mode os.FileMode) {
+	a.fileMode = mode
+}
+
+// SetDirMode changes permissions of the output directory, when Put has to create it.
+// By default it is 0755.
+func (a *Asset) SetDirMode(mode os.FileMode) {
+	a.dirMode = mode
+}
+
+// SetFingerprint enables or disables putting hash of the content in name of the final
+// file. It is enabled by default. When disabled, the final file is named just by the
+// name passed to Put and the extension, like "app.js", or only the extension if the
+// name is empty. Note that the name then doesn't change when the content changes, so
+// you have to take care of cache busting yourself, e.g., with query strings. Changes
+// are still detected by the hashes in the info file.
+func (a *Asset) SetFingerprint(fingerprint bool) {
+	a.fingerprint = fingerprint
+}
+
+// SetInlineAssets makes CSS assets embed the files they reference by url(), like
+// images and fonts, as data URIs, if the files are not larger than maxBytes. It's
+// disabled by default, and 0 disables it. Addresses are resolved relative to the
+// directory of the input file that has them, after compilation and before
+// compression. Files that don't exist or are larger are left as references, and so
+// are absolute addresses and URLs. Put notices changes of the embedded files, like
+// changes of the inputs.
+func (a *Asset) SetInlineAssets(maxBytes int) {
+	a.inlineMax = maxBytes
+}
+
+// SetURLBase makes relative url() references of CSS, which are relative to directory
+// from, like "assets/style", relative to directory to, like "static", which is where
+// the final file is put, so that "url(../img/x.png)" becomes
+// "url(../assets/img/x.png)". Absolute addresses and data URIs are left as they are.
+// It's done after compilation and before compression. Passing empty strings disables
+// it, which is the default.
+func (a *Asset) SetURLBase(from, to string) {
+	if len(from) == 0 && len(to) == 0 {
+		a.urlBase = [2]string{}
+		return
+	}
+	a.urlBase = [2]string{path.Clean(filepath.ToSlash(from)), path.Clean(filepath.ToSlash(to))}
+}
+
+// SetInlineImports enables or disables replacing @import rules of plain CSS input
+// files with content of the files they import. It is disabled by default. Addresses
+// are resolved relative to the importing file, and imported files can import other
+// files too, but circular imports are reported as errors. Imports of URLs, absolute
+// addresses, and imports with media queries are left as they are. LESS, Sass, and
+// Stylus inputs don't need this, since their compilers inline imports themselves.
+func (a *Asset) SetInlineImports(inline bool) {
+	a.imports = inline
+}
+
+// SetAutoprefix enables or disables adding vendor prefixes to CSS, by passing it
+// through "postcss" with the autoprefixer plugin. It is disabled by default. Prefixes
+// are added after compilation and inlining of imports, and before compression. It
+// doesn't affect JS assets.
+func (a *Asset) SetAutoprefix(autoprefix bool) {
+	a.autoprefix = autoprefix
+}
+
+// SetDedup enables or disables dropping inputs with the same content as an earlier
+// input, e.g., when a file matches two of the globs. It is disabled by default, so
+// that inputs can be repeated on purpose. The first of the same inputs is kept in its
+// place.
+func (a *Asset) SetDedup(dedup bool) {
+	a.dedup = dedup
+}
+
+// SetSeparator changes what's put between inputs when they're joined into the final
+// file. By default it is a newline, which keeps a JS file that doesn't end in a
+// semicolon from running into the next one. LESS, Sass, Stylus, CoffeeScript,
+// TypeScript, and JSX inputs that are joined before compilation are always separated
+// by a newline. sep is copied, so it's safe to modify it after the call.
+func (a *Asset) SetSeparator(sep []byte) {
+	a.separator = append([]byte{}, sep...)
+}
+
+// SetTimeout limits how long each run of an external tool, like lessc or coffee, may
+// take. Tools that take longer are killed, and the build fails with an error. It's
+// useful in CI, where a stuck tool shouldn't block everything. By default it is zero,
+// which means no limit.
+func (a *Asset) SetTimeout(d time.Duration) {
+	a.timeout = d
+}
+
+// SetVariables sets values of variables of LESS and Sass inputs, replacing the ones
+// that are set before. Values are given as they're written in LESS or Sass, like
+// "#336699" or "10px". They override values that are set in LESS inputs, and are
+// declared before Sass inputs, so Sass inputs should declare them with !default. It's
+// useful for making themes from the same inputs; assets with different variables
+// have different final files. vars is copied, so it's safe to modify it after the
+// call.
+func (a *Asset) SetVariables(vars map[string]string) {
+	a.variables = nil
+	for name, value := range vars {
+		a.variables = append(a.variables, name+"="+value)
+	}
+	sort.Strings(a.variables)
+}
+
+// SetPreserveLicense makes compression keep comments that start with "/*!", which
+// usually hold licenses that must be kept with the code. It is disabled by default.
+// When enabled, these comments are taken out before compression, and put on top of
+// the compressed output, in their order.
+func (a *Asset) SetPreserveLicense(preserve bool) {
+	a.license = preserve
+}
+
+// SetEmitDebug makes Put write the final file before compression next to it too, with
+// the same name but a ".debug" before the extension, like "app-5d41402a.debug.js",
+// which is useful for debugging problems that only happen in production. Put still
+// returns name of the compressed file. It is disabled by default.
+func (a *Asset) SetEmitDebug(emitDebug bool) {
+	a.emitDebug = emitDebug
+}
+
+// SetLicenseFile makes Put collect comments that start with "/*!", which usually hold
+// licenses, from all the inputs, and write them to a file next to the final file, with
+// the same name but a ".LICENSE.txt" extension instead of ".css" or ".js". It's
+// useful for audits. The file is not written if there's no such comment. It is
+// disabled by default, and works whether or not SetPreserveLicense keeps the comments
+// in the final file.
+func (a *Asset) SetLicenseFile(licenseFile bool) {
+	a.licenseFile = licenseFile
+}
+
+// SetNameTemplate changes how name of the final file is made. tmpl is a text/template
+// that's executed with fields .Name, the name passed to Put, .Hash, the hash of the
+// content, and .Ext, the extension. By default it is
+//
+//         {{if .Name}}{{.Name}}-{{end}}{{.Hash}}{{.Ext}}
+//
+// Names can have directories, like "{{.Hash}}/{{.Name}}{{.Ext}}", which are created
+// in the output directory, but can't be outside of it. It returns an error if tmpl
+// is not a valid template. It doesn't affect assets without fingerprint.
+func (a *Asset) SetNameTemplate(tmpl string) error {
+	if _, err := makeName(tmpl, nameData{"name", "d41d8cd98f00b204e9800998ecf8427e", ".js"}); err != nil {
+		return err
+	}
+	a.nameTemplate = tmpl
+	return nil
+}
+
+// sassStyles are the output styles of the Sass compiler.
+var sassStyles = map[string]bool{"nested": true, "expanded": true, "compact": true,
+	"compressed": true}
+
+// SetSassStyle sets the output style of the Sass compiler, which is one of "nested",
+// "expanded", "compact", and "compressed". Passing an empty string restores the
+// default of the compiler. Since "compressed" makes Sass compress its output, assets
+// whose inputs are all Sass are not compressed again by the CSS compressor; assets
+// that have other CSS inputs too are still compressed as a whole.
+func (a *Asset) SetSassStyle(style string) error {
+	if len(style) > 0 && !sassStyles[style] {
+		return errors.New("assets: unsupported Sass style \"" + style + "\"")
+	}
+	a.sassStyle = style
+	return nil
+}
+
+// toolKeys are the keys of the external tools that SetToolArgs accepts.
+var toolKeys = map[string]bool{"less": true, "coffee": true, "csscompress": true,
+	"jscompress": true}
+
+// SetToolArgs adds args to the arguments that are passed to an external tool, like
+// "--math=strict" for lessc. tool is one of "less", "coffee", "csscompress", and
+// "jscompress", which is the CSS or JS compressor of the Asset, other than the native
+// ones. Calling it again for the same tool replaces its arguments, and calling it
+// without args removes them. Changing the arguments is noticed like changing the
+// inputs.
+func (a *Asset) SetToolArgs(tool string, args ...string) error {
+	if !toolKeys[tool] {
+		return errors.New("assets: unknown tool \"" + tool + "\"")
+	}
+	if len(args) == 0 {
+		delete(a.toolArgs, tool)
+		return nil
+	}
+	if a.toolArgs == nil {
+		a.toolArgs = make(map[string][]string)
+	}
+	a.toolArgs[tool] = append([]string{}, args...)
+	return nil
+}
+
+// SetVersionDir makes Put write the output files into directory v in the output
+// directory, like "v123", which is created if needed. Names returned by Put include
+// v, like "v123/app-5d41402a.js", so that they're still relative to the output
+// directory. It's useful for busting caches by versions, especially with
+// SetFingerprint(false), which keeps the rest of the names stable. Changing v is
+// noticed like changing the inputs. Passing an empty string restores the default.
+func (a *Asset) SetVersionDir(v string) error {
+	if len(v) == 0 {
+		a.versionDir = ""
+		return nil
+	}
+	clean := path.Clean(v)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") ||
+		strings.Contains(v, `\`) {
+		return fmt.Errorf("assets: invalid version directory %q", v)
+	}
+	a.versionDir = clean
+	return nil
+}
+
+// SetToolEnv sets environment variables of the external tools, in addition to the
+// environment of the program, which is not changed. Variables in env override the
+// ones in the environment with the same names. Tools are looked up in PATH of env,
+// if it has one, so that a specific version of a tool can be picked, e.g., one that's
+// installed by a version manager. env is copied, so it's safe to modify it after the
+// call. Environment variables don't apply to Runner.
+func (a *Asset) SetToolEnv(env map[string]string) {
+	a.toolEnv = nil
+	for name, value := range env {
+		a.toolEnv = append(a.toolEnv, name+"="+value)
+	}
+	sort.Strings(a.toolEnv)
+}
+
+// SetCompileCache makes the Asset keep outputs of LESS, Sass, Stylus, CoffeeScript,
+// JSX, and Babel compilers in dir, and reuse them instead of running the compilers
+// again for the same inputs, even when they're in other assets. Entries are found by
+// the input, the compiler and its arguments, and modification time of the compiler,
+// so that upgrading it invalidates them. dir is created if it doesn't exist, and can
+// be shared by assets. It's disabled by default. TypeScript outputs are not cached.
+func (a *Asset) SetCompileCache(dir string) {
+	a.compileCache = dir
+}
+
+// SetInfoDir makes Put keep the info file of the asset in dir, instead of the output
+// directory, so that the output directory has only the files that should be served.
+// dir is created if it doesn't exist. Info files are named after the name and
+// extension of the asset, so assets that are put in different output directories
+// with the same name should not share dir.
+func (a *Asset) SetInfoDir(dir string) {
+	a.infoDir = dir
+}
+
+// SetProgress sets a function that's called when each step of Put, or Build, starts,
+// which is useful for showing progress of builds. Steps are "expand", when globs are
+// expanded, "read", "compile", once for each input that's compiled, with name of the
+// input as detail, "join", "compress", with name of the compressor as detail, and
+// "write", with name of the final file as detail. If Put finds the asset unchanged,
+// "cache-hit" is the last step, with name of the final file as detail. Steps that
+// aren't needed are skipped. It's unset by default.
+func (a *Asset) SetProgress(progress func(step, detail string)) {
+	a.progress = progress
+}
+
+// SetTransform sets a function that changes content of each input before it's
+// compiled, like for replacing feature flags in the code. It gets extension of the
+// input, like ".less", and its content, and returns the new content, which is what
+// gets hashed, compiled, and joined. An error of transform stops the build. Since
+// changes in what transform does can't be seen from stamps of the inputs, Put always
+// reads and transforms them when it's set. It's unset by default.
+func (a *Asset) SetTransform(transform func(ext string, in []byte) ([]byte, error)) {
+	a.transform = transform
+}
+
+// SetPostBuild sets a function that's called at the end of Put, after the final file
+// and the info file are saved, with the name of the final file, and its path in the
+// output directory. It's only called when the asset is built again, not when Put
+// finds it unchanged, and it's useful for things like purging a CDN. If it returns an
+// error, Put returns it too, although the files are already saved. It's unset by
+// default.
+func (a *Asset) SetPostBuild(postBuild func(fname, fullpath string) error) {
+	a.postBuild = postBuild
+}
+
+// SetLogger makes the Asset log steps of its builds, like joining and compiling of the
+// inputs, and name of the final file, to l. By default, nothing is logged.
+func (a *Asset) SetLogger(l Logger) {
+	a.logger = l
+}
+
+// SetManifest enables or disables recording name of the final file in the manifest
+// file of the output directory. It is disabled by default. See ManifestFname for
+// more information.
+func (a *Asset) SetManifest(manifest bool) {
+	a.manifest = manifest
+}
+
+// SetHash changes the hash algorithm used for naming the final file and detecting
+// changes of the inputs. name is one of "md5", "sha1", "sha256", or "sha512". It's
+// "md5" by default. Changing the algorithm makes the next call to Put build the
+// asset again.
+func (a *Asset) SetHash(name string) error {
+	if _, ok := hashFuncs[name]; !ok {
+		return errors.New("assets: unsupported hash algorithm \"" + name + "\"")
+	}
+	a.hashName = name
+	return nil
+}
+
+// SetHashLength truncates the hash in the name of the final file to n hex characters,
+// to make the name shorter. The full hash is still used for detecting changes of the
+// inputs. n should be at least 6, to keep the chance of collisions low. Passing 0
+// restores the default, which is the full length of the hash.
+func (a *Asset) SetHashLength(n int) error {
+	if n != 0 && n < minHashLength {
+		return fmt.Errorf("assets: hash length %d is shorter than %d", n, minHashLength)
+	}
+	a.hashLength = n
+	return nil
+}
+
+// SetCSSCompressor changes the tool that compresses CSS output. By default it is
+// "yuicompressor". cmd can be "yuicompressor" or "cleancss", or "native", which is a
+// simple compressor built into this package that removes comments and extra
+// whitespace without running any external tool, or any other command that reads
+// CSS from stdin and writes compressed CSS to stdout. If args are given, they are
+// passed to cmd instead of the default arguments.
+func (a *Asset) SetCSSCompressor(cmd string, args ...string) {
+	if c, ok := cssCompressors[cmd]; ok && len(args) == 0 {
+		a.cssCompressor = c
+		return
+	}
+	a.cssCompressor = append([]string{cmd}, args...)
+}
+
+// SetCSSMinifyLevel changes how aggressive CSS compression is. Level 0 only removes
+// comments and extra whitespace, using the native compressor, so that order of the
+// rules, which matters for the cascade, is never changed. Level 1 and 2 use the CSS
+// compressor, and only cleancss tells them apart, by its -O1 and -O2 options, which
+// optimize single properties and whole rules respectively. Other compressors,
+// including yuicompressor, which is the default, have no levels and run as they are,
+// so for them level 1 is the same as level 2, and only level 0 is safer. The default
+// is 2, which is full compression.
+func (a *Asset) SetCSSMinifyLevel(level int) error {
+	if level < 0 || level > 2 {
+		return fmt.Errorf("assets: CSS minify level %d is not between 0 and 2", level)
+	}
+	a.cssLevel = level
+	return nil
+}
+
+// SetJSCompressor changes the tool that compresses JS output. By default it is
+// "yuicompressor". cmd can be one of "yuicompressor", "terser", or "uglifyjs", which
+// are run with suitable arguments, or "native", which is a port of JSMin built into
+// this package that removes comments and extra whitespace but doesn't rename
+// anything, or any other command that reads JS from stdin and writes compressed JS to
+// stdout. If args are given, they are passed to cmd instead of the default arguments.
+func (a *Asset) SetJSCompressor(cmd string, args ...string) {
+	if c, ok := jsCompressors[cmd]; ok && len(args) == 0 {
+		a.jsCompressor = c
+		return
+	}
+	a.jsCompressor = append([]string{cmd}, args...)
+}
+
+// SetJoin can change behaviour of Asset in handling multiple LESS and CoffeeScript
+// files. By default, if multiple .less or mulitple .coffee files are provided, Asset
+// joins them into a single one before compiling them into CSS and JavaScript. This is
+// useful for separating LESS and CoffeeScript code into multiple files. You can
+// disable this behavior by setting Join to false.
+//
+// Please note that Asset should preserve order of input files, so if you provide it
+// with
+//
+//         a.Add("a.coffee", "b.js", "c.coffee", "d.coffee")
+//
+// only third and fourth files are joined before compilation. SetJoin sets both
+// SetJoinCSS and SetJoinJS.
+func (a *Asset) SetJoin(join bool) {
+	a.joinCSS, a.joinJS = join, join
+}
+
+// SetJoinCSS is like SetJoin, but only changes joining of LESS, Sass, and Stylus
+// files, so that they can be compiled one by one while CoffeeScript files are still
+// joined, or the other way around.
+func (a *Asset) SetJoinCSS(join bool) {
+	a.joinCSS = join
+}
+
+// SetJoinJS is like SetJoin, but only changes joining of CoffeeScript, TypeScript,
+// and JSX files.
+func (a *Asset) SetJoinJS(join bool) {
+	a.joinJS = join
+}
+
+// Join modes of CoffeeScript files. See SetJoinMode.
+const (
+	JoinSource = "source" // join sources, and compile them together
+	JoinIIFE   = "iife"   // compile each file, and join the outputs
+)
+
+// SetJoinMode changes how CoffeeScript files are joined, when joining is enabled. By
+// default, it's JoinSource, which joins the sources and compiles them at once, so
+// that the files share one scope. JoinIIFE compiles each file on its own instead, so
+// that each one gets wrapped in its own function, which keeps libraries that expect
+// their own scope working. The outputs are still joined into the final file.
+func (a *Asset) SetJoinMode(mode string) error {
+	if mode != JoinSource && mode != JoinIIFE {
+		return errors.New("assets: unsupported join mode \"" + mode + "\"")
+	}
+	a.joinMode = mode
+	return nil
+}
+
+// SetCoffeeBare enables or disables compiling CoffeeScript with the --bare flag of
+// coffee, which leaves out the function that normally wraps the output. It's useful
+// when the code is joined with code of other module systems. It is disabled by
+// default.
+func (a *Asset) SetCoffeeBare(bare bool) {
+	a.coffeeBare = bare
+}
+
+// SetBabel enables or disables passing JS inputs through babel before compression.
+// It is disabled by default. Babel runs on each input after CoffeeScript and
+// TypeScript are compiled, so it's useful when your JS files use ES2015+ syntax that
+// the compressor can't parse.
+func (a *Asset) SetBabel(babel bool) {
+	a.babel = babel
+}
+
+// SetFS makes the Asset read its input files from fsys, instead of the operating
+// system's filesystem. It's useful for assets that are embedded in the program using
+// an embed.FS. File names and globs are then interpreted as described in io/fs, which
+// means they should be slash-separated and unrooted. Output files are still written
+// to the operating system's filesystem.
+func (a *Asset) SetFS(fsys fs.FS) {
+	a.fsys = fsys
+}
+
+// expandGlobs fills inputs of a with sources, replacing globs in file names with real
+// file names.
+func (a *Asset) expandGlobs() error {
+	l, err := a.globInputs()
+	if err != nil {
+		return err
+	}
+	a.inputs = l
+	return nil
+}
+
+// globInputs returns the inputs that sources of a make, with globs in file names
+// replaced by real file names. Matches of each glob are sorted, so that the same files
+// always make the same output, and then inputs are sorted by their priorities.
+func (a *Asset) globInputs() ([]input, error) {
+	ignores, err := a.ignorePatterns()
+	if err != nil {
+		return nil, err
+	}
+	var l []input
+	for _, src := range a.sources {
+		if len(src.fname) == 0 {
+			// added from memory
+			l = append(l, src)
+			continue
+		}
+		if isURL(src.fname) {
+			l = append(l, input{fname: src.fname, ext: urlExt(src.fname)})
+			continue
+		}
+		matches, err := a.glob(a.resolve(src.fname))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 && a.strictGlobs {
+			return nil, fmt.Errorf("assets: %q doesn't match any file", src.fname)
+		}
+		// order of matches shouldn't depend on the filesystem
+		sort.Strings(matches)
+		for _, filename := range matches {
+			if ok, err := ignored(ignores, filename); err != nil {
+				return nil, err
+			} else if ok {
+				continue
+			}
+			l = append(l, input{fname: filename, ext: path.Ext(filename)})
+		}
+	}
+	if len(a.priorities) > 0 {
+		sort.SliceStable(l, func(i, j int) bool {
+			return a.priority(l[i].fname) < a.priority(l[j].fname)
+		})
+	}
+	return l, nil
+}
+
+// readInputs loads content of input files into inputs variable of a, with Windows
+// line endings replaced by newlines. Empty inputs are dropped, since they'd only
+// waste a compiler run, and ErrNoInput is returned if all of them are empty.
+func (a *Asset) readInputs() error {
+	l := a.inputs[:0]
+	for _, inp := range a.inputs {
+		if len(inp.fname) > 0 {
+			b, err := a.readFile(inp.fname)
+			if err != nil {
+				return err
+			}
+			inp.bytes = b
+		}
+		// globs can match files like images by mistake, and tools choke on them
+		if !a.allowBinary && isBinary(inp.bytes) {
+			return fmt.Errorf("assets: %s is not a text file; "+
+				"fix the globs, or allow it by SetAllowBinary", inp)
+		}
+		// line endings shouldn't matter for compilers, or hashes
+		if bytes.Contains(inp.bytes, []byte("\r\n")) {
+			inp.bytes = bytes.Replace(inp.bytes, []byte("\r\n"), []byte("\n"), -1)
+		}
+		if len(inp.bytes) == 0 {
+			a.logf("skipped %s, it's empty", inp)
+			continue
+		}
+		if mapFname, ok := a.mapFiles[filepath.Clean(inp.fname)]; ok && len(inp.fname) > 0 {
+			m, err := a.readDep(mapFname)
+			if err != nil {
+				return err
+			}
+			inp.bytes, inp.sourceMap = removeSourceMapComment(inp.bytes), m
+		}
+		l = append(l, inp)
+	}
+	a.inputs = l
+	if len(a.inputs) == 0 {
+		return ErrNoInput
+	}
+	return nil
+}
+
+// sniffLen is the number of bytes in the beginning of inputs that isBinary checks.
+const sniffLen = 512
+
+// isBinary reports whether b looks like the content of a binary file, rather than
+// text: a null byte, or many control characters in the beginning of it.
+func isBinary(b []byte) bool {
+	if len(b) > sniffLen {
+		b = b[:sniffLen]
+	}
+	control := 0
+	for _, c := range b {
+		switch {
+		case c == 0:
+			return true
+		case c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != '\v':
+			control++
+		}
+	}
+	return control*10 > len(b)
+}
+
+// readDep reads file fname that the output depends on, besides the inputs, and
+// records its stamp.
+func (a *Asset) readDep(fname string) ([]byte, error) {
+	fi, err := a.statFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.readFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	a.depStamps = append(a.depStamps, stamp{Fname: fname, ModTime: fi.ModTime(),
+		Size: fi.Size()})
+	return b, nil
+}
+
+// statFile returns info of the named input file.
+func (a *Asset) statFile(name string) (fs.FileInfo, error) {
+	if a.fsys != nil {
+		return fs.Stat(a.fsys, name)
+	}
+	return os.Stat(name)
+}
+
+// dedupInputs removes inputs that have the same extension and content as an earlier
+// input, keeping order of the rest.
+func (a *Asset) dedupInputs() error {
+	seen := make(map[string]string)
+	l := a.inputs[:0]
+	for _, inp := range a.inputs {
+		sum, err := hash(inp.bytes, hashFuncs[a.hashName])
+		if err != nil {
+			return err
+		}
+		if first, ok := seen[inp.ext+sum]; ok {
+			a.logf("skipped %s, same as %s", inp, first)
+			continue
+		}
+		seen[inp.ext+sum] = inp.String()
+		l = append(l, inp)
+	}
+	a.inputs = l
+	return nil
+}
+
+// openFile opens the named input file, in the file system of a if it has one.
+func (a *Asset) openFile(name string) (io.ReadCloser, error) {
+	if a.fsys != nil {
+		return a.fsys.Open(name)
+	}
+	return os.Open(name)
+}
+
+// readFile reads the named input file, or fetches it if it's a URL.
+func (a *Asset) readFile(name string) ([]byte, error) {
+	if isURL(name) {
+		return fetch(name)
+	}
+	if a.fsys != nil {
+		return fs.ReadFile(a.fsys, name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// joinFiles joins subsequent LESS, Sass, Stylus, CoffeeScript, TypeScript, or JSX
+// inputs into single ones.
+//
+// To preserve of the input files, only sequential files with the same extension are
+// joined as a group. That means that if we have, for example, files "a.coffee",
+// "b.js", "c.coffee", and "d.coffee", only third and fourth files are joined.
+func (a *Asset) joinFiles() {
+	// can't use range because the list will be changed during the loop
+	for i := 0; i < len(a.inputs); i++ {
+		ext := a.inputs[i].ext
+		if !joinable(ext) || !a.joins(family(ext)) {
+			continue
+		}
+		// compiled CoffeeScript files are wrapped in their own functions
+		if ext == ".coffee" && a.joinMode == JoinIIFE {
+			continue
+		}
+		// bytes keeps content of current group of joinable files, starting
+		// from file at a.inputs[i]
+		bytes := make([]byte, 0)
+		n := 0
+		var names []string
+		for j := i; j < len(a.inputs); j++ {
+			if a.inputs[j].ext == ext {
+				if j > i {
+					bytes = append(bytes, defaultSeparator...)
+				}
+				bytes = append(bytes, a.inputs[j].bytes...)
+				names = append(names, a.inputs[j].String())
+				n++
+			} else {
+				// first file with another extension ends the sequence
+				break
+			}
+		}
+		// n == 1 means current file is joinable, but its alone, and doesn't
+		// need anything to be done
+		if n < 2 {
+			continue
+		}
+
+		// join all the files
+		a.logf("joined %s", strings.Join(names, ", "))
+		parts := append([]input{}, a.inputs[i:i+n]...)
+		a.inputs[i].bytes, a.inputs[i].parts = bytes, parts
+		// delete subsequent joined files
+		a.inputs = append(a.inputs[:i+1], a.inputs[i+n:]...)
+	}
+}
+
+// joins reports whether a joins inputs that are compiled into ext, which is either
+// ".css" or ".js".
+func (a *Asset) joins(ext string) bool {
+	if a.dev {
+		return false
+	}
+	if ext == ".css" {
+		return a.joinCSS
+	}
+	return a.joinJS
+}
+
+// family returns extension of the output that is made from an input with extension
+// ext, which is either ".css" or ".js", or an empty string if ext is not supported.
+func family(ext string) string {
+	switch ext {
+	case ".js", ".mjs", ".cjs", ".coffee", ".ts", ".jsx":
+		return ".js"
+	case ".css", ".less", ".scss", ".sass", ".styl":
+		return ".css"
+	}
+	return ""
+}
+
+// joinable reports whether inputs with extension ext can be joined before
+// compilation.
+func joinable(ext string) bool {
+	switch ext {
+	case ".coffee", ".ts", ".jsx", ".less", ".scss", ".sass", ".styl":
+		return true
+	}
+	return false
+}
+
+// makeHashes generates hashes of inputs. Hashes other than MD5 are prefixed with
+// name of their algorithm, so that changing the algorithm is detected as a change.
+// Hashes of LESS input files include the files they import, and hashes of CSS inputs
+// include the files they inline, since editing them changes the output too.
+func (a *Asset) makeHashes() error {
+	seen := make(map[string]bool)
+	inlinedSeen := make(map[string]bool)
+	for _, inp := range a.inputs {
+		var deps []byte
+		if inp.ext == ".less" {
+			var err error
+			if deps, err = a.inputLessDeps(inp, seen); err != nil {
+				return err
+			}
+		}
+		// files inlined into CSS change the output too
+		if a.inlineMax > 0 && family(inp.ext) == ".css" {
+			inlined, err := a.inlinedDeps(inp, inlinedSeen)
+			if err != nil {
+				return err
+			}
+			deps = append(deps, inlined...)
+		}
+		// parts are streamed into the hash, instead of being copied together
+		r := io.MultiReader(bytes.NewReader(inp.bytes), bytes.NewReader(inp.sourceMap),
+			bytes.NewReader(deps))
+		sum, err := hashReader(r, hashFuncs[a.hashName])
+		if err != nil {
+			return err
+		}
+		if a.hashName != "md5" {
+			sum = a.hashName + ":" + sum
+		}
+		a.hashes = append(a.hashes, sum)
+	}
+	// options that change the final file are hashed too
+	sum, err := a.optionsHash()
+	if err != nil {
+		return err
+	}
+	if len(sum) > 0 {
+		a.hashes = append(a.hashes, sum)
+	}
+	return nil
+}
+
+// optionsHash returns hash of the options of a, or an empty string if it has none.
+func (a *Asset) optionsHash() (string, error) {
+	opts := a.options()
+	if len(opts) == 0 {
+		return "", nil
+	}
+	sum, err := hash([]byte(strings.Join(opts, "\n")), hashFuncs[a.hashName])
+	if err != nil {
+		return "", err
+	}
+	return "options:" + sum, nil
+}
+
+// sameStreamedHashes reports whether hashes of the inputs are the ones in inf, by
+// streaming the inputs from disk into the hash function, instead of reading them into
+// memory like load. If so, the hashes are kept in a. Inputs that are changed after
+// they're read, like by joining or transforming them, or that import other files,
+// can't be hashed this way, and false is returned for them.
+func (a *Asset) sameStreamedHashes(inf *info) (bool, error) {
+	if inf == nil || a.imports || a.transform != nil || a.dedup || a.inlineMax > 0 {
+		return false, nil
+	}
+	for _, inp := range a.inputs {
+		if isURL(inp.fname) || joinable(inp.ext) {
+			return false, nil
+		}
+		if _, ok := a.mapFiles[filepath.Clean(inp.fname)]; ok && len(inp.fname) > 0 {
+			return false, nil
+		}
+	}
+	var hashes []string
+	for _, inp := range a.inputs {
+		sum, ok, err := a.streamHash(inp)
+		if err != nil || !ok {
+			return false, err
+		}
+		// empty inputs are skipped, like by readInputs
+		if len(sum) > 0 {
+			hashes = append(hashes, sum)
+		}
+	}
+	sum, err := a.optionsHash()
+	if err != nil {
+		return false, err
+	}
+	if len(sum) > 0 {
+		hashes = append(hashes, sum)
+	}
+	if !equalStrings(inf.Hashes, hashes) {
+		return false, nil
+	}
+	a.hashes = hashes
+	return true, nil
+}
+
+// streamHash returns hash of input inp, like makeHashes, but copies content of the
+// input file into the hash function, instead of reading it. Line endings are
+// normalized on the way, like readInputs does. It returns an empty sum for empty
+// inputs, and false for binary ones, which only load reports.
+func (a *Asset) streamHash(inp input) (sum string, ok bool, err error) {
+	var r io.Reader = bytes.NewReader(inp.bytes)
+	if len(inp.fname) > 0 {
+		f, err := a.openFile(inp.fname)
+		if err != nil {
+			return "", false, err
+		}
+		defer f.Close()
+		r = f
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", false, err
+	}
+	head = head[:n]
+	if n == 0 {
+		return "", true, nil
+	}
+	if !a.allowBinary && isBinary(head) {
+		return "", false, nil
+	}
+	h := hashFuncs[a.hashName].New()
+	w := &lfWriter{w: h}
+	if _, err = w.Write(head); err != nil {
+		return "", false, err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		return "", false, err
+	}
+	if err = w.Flush(); err != nil {
+		return "", false, err
+	}
+	sum = fmt.Sprintf("%x", h.Sum(nil))
+	if a.hashName != "md5" {
+		sum = a.hashName + ":" + sum
+	}
+	return sum, true, nil
+}
+
+// lfWriter writes to w what's written to it, with "\r\n" line endings replaced by
+// "\n". A "\r" at the end of a write is held until the next one, or Flush.
+type lfWriter struct {
+	w   io.Writer
+	cr  bool   // is a "\r" held?
+	buf []byte // content of the last write, with new line endings
+}
+
+// Write implements io.Writer.
+func (l *lfWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n == 0 {
+		return 0, nil
+	}
+	if l.cr && p[0] != '\n' {
+		if _, err := l.w.Write([]byte{'\r'}); err != nil {
+			return 0, err
+		}
+	}
+	l.cr = p[n-1] == '\r'
+	if l.cr {
+		p = p[:n-1]
+	}
+	// lines are copied into buf, which is kept for the next writes
+	l.buf = l.buf[:0]
+	for {
+		i := bytes.Index(p, []byte("\r\n"))
+		if i < 0 {
+			break
+		}
+		l.buf = append(append(l.buf, p[:i]...), '\n')
+		p = p[i+2:]
+	}
+	l.buf = append(l.buf, p...)
+	if _, err := l.w.Write(l.buf); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Flush writes the "\r" that's held, if any.
+func (l *lfWriter) Flush() error {
+	if !l.cr {
+		return nil
+	}
+	l.cr = false
+	_, err := l.w.Write([]byte{'\r'})
+	return err
+}
+
+// options returns the settings of a that change the final file without changing the
+// inputs, so that changing them can be detected as a change of the asset.
+func (a *Asset) options() []string {
+	var opts []string
+	if len(a.banner) > 0 {
+		opts = append(opts, "banner="+a.banner)
+	}
+	if !a.fingerprint {
+		opts = append(opts, "fingerprint=false")
+	}
+	if !a.compressCSS {
+		opts = append(opts, "compress-css=false")
+	}
+	if !a.compressJS {
+		opts = append(opts, "compress-js=false")
+	}
+	if a.sourceMaps {
+		opts = append(opts, "source-maps=true")
+	}
+	if !equalStrings(a.cssCompressor, cssCompressors["yuicompressor"]) {
+		opts = append(opts, "css-compressor="+strings.Join(a.cssCompressor, " "))
+	}
+	if !equalStrings(a.jsCompressor, jsCompressors["yuicompressor"]) {
+		opts = append(opts, "js-compressor="+strings.Join(a.jsCompressor, " "))
+	}
+	if a.gzip {
+		opts = append(opts, "gzip=true")
+	}
+	if a.brotli {
+		opts = append(opts, "brotli=true")
+	}
+	if a.hashLength > 0 {
+		opts = append(opts, "hash-length="+strconv.Itoa(a.hashLength))
+	}
+	if a.inlineMax > 0 {
+		opts = append(opts, "inline="+strconv.Itoa(a.inlineMax))
+	}
+	if a.imports {
+		opts = append(opts, "imports=true")
+	}
+	if a.autoprefix {
+		opts = append(opts, "autoprefix=true")
+	}
+	if a.babel {
+		opts = append(opts, "babel=true")
+	}
+	if a.dedup {
+		opts = append(opts, "dedup=true")
+	}
+	if a.license {
+		opts = append(opts, "license=true")
+	}
+	if len(a.mapFiles) > 0 {
+		var maps []string
+		for fname, mapFname := range a.mapFiles {
+			maps = append(maps, fname+"="+mapFname)
+		}
+		sort.Strings(maps)
+		opts = append(opts, "maps="+strings.Join(maps, ";"))
+	}
+	if a.nameTemplate != defaultNameTemplate {
+		opts = append(opts, "name="+a.nameTemplate)
+	}
+	if len(a.variables) > 0 {
+		opts = append(opts, "variables="+strings.Join(a.variables, ";"))
+	}
+	if !bytes.Equal(a.separator, defaultSeparator) {
+		opts = append(opts, "separator="+string(a.separator))
+	}
+	if a.dev {
+		opts = append(opts, "dev=true")
+	}
+	if len(a.sassStyle) > 0 {
+		opts = append(opts, "sass-style="+a.sassStyle)
+	}
+	if a.licenseFile {
+		opts = append(opts, "license-file=true")
+	}
+	if len(a.versionDir) > 0 {
+		opts = append(opts, "version="+a.versionDir)
+	}
+	if a.emitDebug {
+		opts = append(opts, "debug=true")
+	}
+	if a.transform != nil {
+		opts = append(opts, "transform=true")
+	}
+	if a.latest && a.latestSymlink {
+		opts = append(opts, "latest=symlink")
+	} else if a.latest {
+		opts = append(opts, "latest=copy")
+	}
+	if len(a.toolEnv) > 0 {
+		opts = append(opts, "env="+strings.Join(a.toolEnv, ";"))
+	}
+	if len(a.urlBase[0]) > 0 {
+		opts = append(opts, "url-base="+a.urlBase[0]+";"+a.urlBase[1])
+	}
+	if a.cssLevel != 2 {
+		opts = append(opts, "css-level="+strconv.Itoa(a.cssLevel))
+	}
+	if a.joinMode == JoinIIFE {
+		opts = append(opts, "join-mode="+a.joinMode)
+	}
+	if a.coffeeBare {
+		opts = append(opts, "coffee-bare=true")
+	}
+	if len(a.fnameExt) > 0 {
+		opts = append(opts, "output-ext="+a.fnameExt)
+	}
+	if len(a.toolArgs) > 0 {
+		var args []string
+		for tool, l := range a.toolArgs {
+			args = append(args, tool+"="+strings.Join(l, " "))
+		}
+		sort.Strings(args)
+		opts = append(opts, "args="+strings.Join(args, ";"))
+	}
+	return opts
+}
+
+// checkSavedInfo loads asset-info file and see if anything has changed or not. If
+// the input files have the same modification times and sizes as when info was saved,
+// and settings of a are the same, they're not read at all. Otherwise inputs are
+// loaded, and their hashes decide. If save is true and only the stamps have changed,
+// info is saved with the new stamps.
+func (a *Asset) checkSavedInfo(save bool) (chnaged bool, err error) {
+	// stamps are made before reading, so that changes made during the build are
+	// noticed next time. Put may have made them already.
+	if a.inputStamps == nil {
+		if a.inputStamps, err = a.makeStamps(); err != nil {
+			return
+		}
+	}
+	inf, err := a.readInfo()
+	if err != nil {
+		return
+	}
+	// stamps of CSS imports are not known, and what transform does can't be
+	// compared, so they have to be read
+	if inf != nil && !a.imports && a.transform == nil &&
+		equalStrings(inf.Settings, a.settings()) &&
+		sameInputStamps(inf.Stamps, a.inputStamps) && a.sameDepStamps(inf.Deps) {
+		a.depStamps = inf.Deps
+		return false, nil
+	}
+	// inputs are only read into memory when they may have changed
+	same, err := a.sameStreamedHashes(inf)
+	if err != nil {
+		return
+	}
+	if !same {
+		if err = a.load(); err != nil {
+			return
+		}
+		if inf == nil || !equalStrings(inf.Hashes, a.hashes) {
+			a.changed = a.changedInputs(inf)
+			return true, nil
+		}
+	}
+	// contents are the same, so save new stamps to skip reading them next time
+	a.fname, a.sidecars, a.files = a.oldfname, a.oldsidecars, a.oldfiles
+	if !save {
+		return false, nil
+	}
+	return false, a.saveInfo()
+}
+
+// Labels that ChangedInputs returns for rebuilds that are not caused by a single input.
+const (
+	changedInfo    = "<info>"    // info file is missing, or not usable
+	changedCount   = "<count>"   // number of inputs has changed
+	changedOptions = "<options>" // options that change the final file have changed
+)
+
+// changedInputs returns the inputs whose hashes differ from the ones in info file
+// inf, or labels for the changes that can't be blamed on a single input.
+func (a *Asset) changedInputs(inf *info) []string {
+	if inf == nil {
+		return []string{changedInfo}
+	}
+	var l []string
+	old, oldOpts := splitOptionsHash(inf.Hashes)
+	hashes, opts := splitOptionsHash(a.hashes)
+	if opts != oldOpts {
+		l = append(l, changedOptions)
+	}
+	if len(old) != len(hashes) {
+		return append(l, changedCount)
+	}
+	for i, inp := range a.inputs {
+		if old[i] != hashes[i] {
+			l = append(l, inp.String())
+		}
+	}
+	return l
+}
+
+// splitOptionsHash splits hashes made by makeHashes into hashes of the inputs, and
+// hash of the options, which is empty if there's no options.
+func splitOptionsHash(hashes []string) (inputs []string, options string) {
+	if n := len(hashes); n > 0 && strings.HasPrefix(hashes[n-1], "options:") {
+		return hashes[:n-1], hashes[n-1]
+	}
+	return hashes, ""
+}
+
+// readInfo reads info file of a, and records the old final file and its sidecars.
+// It returns nil if there's no info file, or it's not usable by this version.
+func (a *Asset) readInfo() (*info, error) {
+	buf, err := ioutil.ReadFile(a.infoPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var inf info
+	if err = json.Unmarshal(buf, &inf); err != nil {
+		// legacy info files are not JSON. The old files they point to are still
+		// deleted, but the asset is built once more to save new info.
+		a.oldfname, a.oldsidecars = parseLegacyInfo(buf)
+		return nil, nil
+	}
+	a.oldfname, a.oldsidecars, a.oldfiles = inf.Fname, inf.Sidecars, inf.Files
+	a.oldsum, a.oldlatest = inf.SHA256, inf.Latest
+	if inf.Version != infoVersion || len(inf.Fname) == 0 {
+		return nil, nil
+	}
+	return &inf, nil
+}
+
+// settings returns the settings of a that change hashes of the inputs, or the final
+// file.
+func (a *Asset) settings() []string {
+	return append([]string{"hash=" + a.hashName, "join=" + strconv.FormatBool(a.joins(a.ext))},
+		a.options()...)
+}
+
+// equalStrings reports whether a and b have the same strings in the same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stamp identifies content of an input without reading it. Input files are identified
+// by their names, modification times, and sizes, and inputs added from memory by hash
+// of their content.
+type stamp struct {
+	Fname   string    `json:"fname,omitempty"`
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// makeStamps returns stamps of the inputs of a.
+func (a *Asset) makeStamps() ([]stamp, error) {
+	l := make([]stamp, len(a.inputs))
+	for i, inp := range a.inputs {
+		if len(inp.fname) == 0 {
+			sum, err := hash(inp.bytes, hashFuncs[a.hashName])
+			if err != nil {
+				return nil, err
+			}
+			l[i].Hash = sum
+			continue
+		}
+		if isURL(inp.fname) {
+			// remote files have to be fetched to know whether they're changed
+			l[i].Fname = inp.fname
+			continue
+		}
+		fi, err := a.statFile(inp.fname)
+		if err != nil {
+			return nil, err
+		}
+		l[i] = stamp{Fname: inp.fname, ModTime: fi.ModTime(), Size: fi.Size()}
+	}
+	return l, nil
+}
+
+// sameInputStamps reports whether a and b have the same stamps in the same order.
+// Files without modification time, like the ones in embedded filesystems, are never
+// the same.
+func sameInputStamps(a, b []stamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i].Fname) > 0 && a[i].ModTime.IsZero() {
+			return false
+		}
+		if a[i].Fname != b[i].Fname || !a[i].ModTime.Equal(b[i].ModTime) ||
+			a[i].Size != b[i].Size || a[i].Hash != b[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLegacyInfo returns name of the final file and names of its sidecars from a
+// legacy info file. The first line of these files holds names of the final file and
+// its sidecars, and the rest hold the hashes.
+func parseLegacyInfo(buf []byte) (fname string, sidecars []string) {
+	line := strings.SplitN(string(buf), "\n", 2)[0]
+	fnames := strings.Fields(line)
+	if len(fnames) == 0 {
+		return "", nil
+	}
+	return fnames[0], fnames[1:]
+}
+
+// deleteOld deletes old asset file, its sidecars, and asset info file. This is called
+// before generating new file, to keep output directory clean. Old asset file and its
+// sidecars are kept if a is asked to, and so are the files that other assets still
+// have in their info files, since assets with the same output can share files.
+func (a *Asset) deleteOld() error {
+	if len(a.oldfname) > 0 && !a.keepOld {
+		shared, err := a.sharedFiles()
+		if err != nil {
+			return err
+		}
+		for _, fname := range append([]string{a.oldfname}, a.oldsidecars...) {
+			if shared[fname] {
+				a.logf("kept %s, another asset uses it", fname)
+				continue
+			}
+			err := os.Remove(path.Join(a.dir, fname))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		// remove the directories made by name template and version, if they're
+		// empty now
+		for sub := path.Dir(a.oldfname); sub != "."; sub = path.Dir(sub) {
+			os.Remove(path.Join(a.dir, sub))
+		}
+	}
+	// the latest file is replaced by the new build, unless it's not kept anymore
+	if len(a.oldlatest) > 0 && a.oldlatest != a.latestFname() {
+		err := os.Remove(path.Join(a.dir, a.oldlatest))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err := os.Remove(a.infoPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sharedFiles returns names of the files in info files of other assets, which are
+// the info files next to the info file of a.
+func (a *Asset) sharedFiles() (map[string]bool, error) {
+	infoPath := a.infoPath()
+	entries, err := ioutil.ReadDir(path.Dir(infoPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	shared := make(map[string]bool)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "asset-info-") || e.Name() == path.Base(infoPath) {
+			continue
+		}
+		buf, err := ioutil.ReadFile(path.Join(path.Dir(infoPath), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var inf info
+		if err = json.Unmarshal(buf, &inf); err != nil {
+			inf.Fname, inf.Sidecars = parseLegacyInfo(buf)
+		}
+		for _, fname := range append([]string{inf.Fname}, inf.Sidecars...) {
+			shared[fname] = true
+		}
+	}
+	return shared, nil
+}
+
+// compile converts LESS, Sass, Stylus, CoffeeScript, TypeScript, and JSX inputs to
+// CSS and JS. Inputs are compiled by as many workers as concurrency of a allows. The first
+// error stops the workers from picking up more inputs and is returned.
+func (a *Asset) compile() error {
+	workers := a.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := a.compileInput(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	exts := make([]string, len(a.inputs))
+	for i := range a.inputs {
+		exts[i] = a.inputs[i].ext
+	}
+	for i := range a.inputs {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		if run, _ := a.compiler(a.inputs[i].ext); run != nil {
+			a.step("compile", a.inputs[i].String())
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if a.sourceMaps {
+		for i := range a.inputs {
+			b, m, err := extractSourceMap(a.inputs[i].bytes)
+			if err != nil {
+				return err
+			}
+			a.inputs[i].bytes = b
+			if m != nil {
+				a.inputs[i].sourceMap = m
+			}
+		}
+	}
+	for i, inp := range a.inputs {
+		a.compiled = append(a.compiled, CompiledInput{inp.String(), exts[i], inp.bytes})
+	}
+	return nil
+}
+
+// compileInput compiles i-th input of a, if it needs compilation.
+func (a *Asset) compileInput(i int) error {
+	inp := &a.inputs[i]
+	run, ext := a.compiler(inp.ext)
+	if run == nil {
+		return nil
+	}
+	b, err := run(inp.bytes)
+	if err != nil {
+		return a.blame(*inp, run, err)
+	}
+	inp.bytes, inp.ext = b, ext
+	a.logf("compiled %s", inp)
+	return nil
+}
+
+// compiler returns the function that compiles inputs with extension ext, and
+// extension of its output. It returns nil if ext doesn't need compilation.
+func (a *Asset) compiler(ext string) (run func([]byte) ([]byte, error), outExt string) {
+	switch ext {
+	case ".less":
+		return a.runLess, ".css"
+	case ".scss":
+		return a.runSass, ".css"
+	case ".sass":
+		return a.runSassIndented, ".css"
+	case ".styl":
+		return a.runStylus, ".css"
+	case ".coffee":
+		return a.runCoffee, ".js"
+	case ".ts":
+		return a.runTypeScript, ".js"
+	case ".jsx":
+		return a.runJSX, ".js"
+	}
+	return nil, ext
+}
+
+// blame finds out which file is the cause of err, the error of compiling input inp
+// by run. Line numbers in errors of joined inputs don't match the files, so the files
+// that make inp are compiled one by one, and the error of the first one that fails on
+// its own is returned, with its name. If none of them fail, which happens when they
+// only fail together, err is returned. It's only done after failures, so that
+// successful builds don't get any slower.
+func (a *Asset) blame(inp input, run func([]byte) ([]byte, error), err error) error {
+	var cmdErr *CmdError
+	if len(inp.parts) == 0 {
+		if errors.As(err, &cmdErr) {
+			cmdErr.Input = inp.String()
+		}
+		return err
+	}
+	for _, part := range inp.parts {
+		if _, partErr := run(part.bytes); partErr != nil {
+			if errors.As(partErr, &cmdErr) {
+				cmdErr.Input = part.String()
+				return partErr
+			}
+			return fmt.Errorf("assets: %s: %w", part, partErr)
+		}
+	}
+	return err
+}
+
+// transpile passes JS inputs through babel.
+func (a *Asset) transpile() error {
+	for i := 0; i < len(a.inputs); i++ {
+		b, err := a.runBabel(a.inputs[i].bytes)
+		if err != nil {
+			return err
+		}
+		a.inputs[i].bytes = b
+	}
+	return nil
+}
+
+// prefix adds vendor prefixes to CSS inputs using autoprefixer.
+func (a *Asset) prefix() error {
+	for i := 0; i < len(a.inputs); i++ {
+		b, err := a.runAutoprefix(a.inputs[i].bytes)
+		if err != nil {
+			return err
+		}
+		a.inputs[i].bytes = b
+	}
+	return nil
+}
+
+// licenseRe matches comments that start with "/*!", which usually hold licenses.
+var licenseRe = regexp.MustCompile(`(?s)/\*!.*?\*/`)
+
+// extractLicenses removes license comments from b, and returns them, each followed by
+// a newline, and the rest of b.
+func extractLicenses(b []byte) (licenses, rest []byte) {
+	for _, m := range licenseRe.FindAll(b, -1) {
+		licenses = append(licenses, m...)
+		licenses = append(licenses, '\n')
+	}
+	if licenses == nil {
+		return nil, b
+	}
+	return licenses, licenseRe.ReplaceAll(b, nil)
+}
+
+// now returns the current time. Tests replace it to get fixed dates in banners.
+var now = time.Now
+
+// makeBanner wraps text in a comment that's valid in both CSS and JS, replacing
+// "{{date}}" with the date of t.
+func makeBanner(text string, t time.Time) []byte {
+	text = strings.Replace(text, "{{date}}", t.Format("2006-01-02"), -1)
+	// text can't end the comment
+	text = strings.Replace(text, "*/", "* /", -1)
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) == 1 {
+		return []byte("/* " + lines[0] + " */\n")
+	}
+	return []byte("/*\n * " + strings.Join(lines, "\n * ") + "\n */\n")
+}
+
+// step reports the start of a step of the build to the progress function of a, if it
+// has one.
+func (a *Asset) step(step, detail string) {
+	if a.progress != nil {
+		a.progress(step, detail)
+	}
+}
+
+// logf logs a message about the build, if a has a logger.
+func (a *Asset) logf(format string, v ...interface{}) {
+	if a.logger != nil {
+		a.logger.Printf("assets: "+format, v...)
+	}
+}
+
+// writeSidecar writes b to file fname next to the final file, and records it as a
+// sidecar of the final file.
+func (a *Asset) writeSidecar(fname string, b []byte) error {
+	if err := ioutil.WriteFile(path.Join(a.dir, fname), b, a.fileMode); err != nil {
+		return err
+	}
+	a.sidecars = append(a.sidecars, fname)
+	return nil
+}
+
+// infoVersion is version of the format of info files. It changes whenever info files
+// can't be understood by older versions, so that they're treated as changed.
+const infoVersion = 1
+
+// info is what an info file holds, encoded in JSON.
+type info struct {
+	Version  int      `json:"version"`
+	Fname    string   `json:"fname"`              // name of final file
+	Sidecars []string `json:"sidecars,omitempty"` // names of files next to final file
+	Hashes   []string `json:"hashes"`             // hashes of inputs and options
+	Stamps   []stamp  `json:"stamps,omitempty"`   // stamps of inputs, made before reading
+	Settings []string `json:"settings,omitempty"` // settings of asset
+	Deps     []stamp  `json:"deps,omitempty"`     // stamps of files imported by inputs
+	Files    []string `json:"files,omitempty"`    // names of files of inputs, in dev mode
+	SHA256   string   `json:"sha256,omitempty"`   // SHA-256 of final file
+	Latest   string   `json:"latest,omitempty"`   // name of latest file
+}
+
+// saveInfo stores output file name, names of its sidecars, and hashes in info file.
+func (a *Asset) saveInfo() error {
+	// final file is not built again when only the stamps change
+	sum := a.oldsum
+	if a.bytes != nil {
+		sum = fmt.Sprintf("%x", sha256.Sum256(a.bytes))
+	}
+	inf := info{infoVersion, a.fname, a.sidecars, a.hashes, a.inputStamps, a.settings(),
+		a.depStamps, a.files, sum, a.latestFname()}
+	buf, err := json.MarshalIndent(inf, "", "\t")
+	if err != nil {
+		return err
+	}
+	if len(a.infoDir) > 0 {
+		if err = os.MkdirAll(a.infoDir, a.dirMode); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(a.infoPath(), buf, a.fileMode)
+}
+
+// infoPath returns path of info file for asset.
+func (a *Asset) infoPath() string {
+	if len(a.infoDir) > 0 {
+		return path.Join(a.infoDir, a.infoFname())
+	}
+	return path.Join(a.dir, a.infoFname())
+}
+
+// infoFname returns name of info file for asset.
+func (a *Asset) infoFname() string {
+	if len(a.name) > 0 {
+		return "asset-info-" + a.name + "-" + a.fileExt()[1:]
+	}
+	return "asset-info-" + a.fileExt()[1:]
+}
+
+// minHashLength is the minimum length of hash in name of the final file.
+const minHashLength = 6
+
+// hashFuncs keeps the supported hash algorithms, keyed by their names.
+var hashFuncs = map[string]crypto.Hash{
+	"md5":    crypto.MD5,
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha512": crypto.SHA512,
+}
+
+// hash returns hash of b, made by hash function f.
+func hash(b []byte, f crypto.Hash) (sum string, err error) {
+	h := f.New()
+	if _, err = h.Write(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashReader is like hash, but reads the content from r as it hashes it, so that
+// large files don't need to be in memory at once.
+func hashReader(r io.Reader, f crypto.Hash) (sum string, err error) {
+	h := f.New()
+	if _, err = io.Copy(h, r); err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
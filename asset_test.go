@@ -1,11 +1,26 @@
 package assets
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
 	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 const (
@@ -16,9 +31,15 @@ var (
 	files = map[string]string{
 		"a.css":    "body {\n\tcolor: red;\n}",
 		"b.less":   "@c: #444;\n\nb {\ncolor: @c;\n}",
+		"c.scss":   "$c: #333;\n\nc {\n\ti {\n\t\tcolor: $c;\n\t}\n}",
+		"d.styl":   "d\n  color #555\n  a\n    color #666\n",
 		"a.coffee": "window.a = () -> console.log \"a\"\n",
 		"b.coffee": "window.b = () -> console.log \"b\"\n",
 		"c.js":     "window.c = function(){console.log(\"c\");};\n",
+		"e.js":     "var e = 5",
+		"f.js":     "(function(){ window.f = e; })();",
+		"g.mjs":    "export const g = 7;\n",
+		"h.cjs":    "module.exports = { h: 8 };\n",
 	}
 )
 
@@ -34,6 +55,16 @@ func TestAssets(t *testing.T) {
 		[]string{"a.css", "b.less"},
 		"body{color:red}b{color:#444}",
 	}
+	sassTest := assetTest{
+		"test",
+		[]string{"a.css", "c.scss"},
+		"body{color:red}c i{color:#333}",
+	}
+	stylusTest := assetTest{
+		"test",
+		[]string{"d.styl"},
+		"d{color:#555}d a{color:#666}",
+	}
 	jsTest := assetTest{
 		"test",
 		[]string{"a.coffee", "b.coffee"},
@@ -71,6 +102,8 @@ func TestAssets(t *testing.T) {
 	}
 
 	doTest(cssTest, t)
+	doTest(sassTest, t)
+	doTest(stylusTest, t)
 	fname := doTest(jsTest, t)
 	doTest(jsTest2, t)
 	// now first output should be removed
@@ -107,3 +140,2467 @@ func exists(path string) bool {
 	log.Fatalf("can't check for existence of file \"%s\": %v\n", path, err)
 	return false
 }
+
+func TestJSCompressor(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	// a fake terser that prints its name and arguments
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatalf("can't create directory \"%s\": %v\n", bin, err)
+	}
+	script := "#!/bin/sh\ncat > /dev/null\necho terser \"$@\"\n"
+	err := ioutil.WriteFile(filepath.Join(bin, "terser"), []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("can't create fake terser: %v\n", err)
+	}
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("terser")
+	out := filepath.Join(dir, "static")
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(out, fname))
+	if err != nil {
+		t.Fatalf("can't read output file: %v\n", err)
+	}
+	if expected := "terser --compress --mangle\n"; string(buf) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	b, fname, err := a.Build("test")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if string(b) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(b))
+	}
+	sum, _ := hash(b, crypto.MD5)
+	if expected := "test-" + sum + ".js"; fname != expected {
+		t.Fatalf("expected name %s, got %s\n", expected, fname)
+	}
+	if exists(filepath.Join(dir, outDir)) {
+		t.Fatalf("Build created output directory.")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	src := filepath.Join(dir, "c.js")
+
+	a := New(src)
+	a.SetCompress(false)
+	fname, changed, err := a.DryRun(out, "test")
+	if err != nil {
+		t.Fatalf("DryRun returned error: %v\n", err)
+	}
+	if !changed || exists(out) {
+		t.Fatalf("DryRun didn't report the change, or wrote to disk.")
+	}
+	putFname, err := a.Put(out, "test")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if fname != putFname {
+		t.Fatalf("expected name %s, got %s\n", putFname, fname)
+	}
+	// new modification time with the same content changes nothing, not even info
+	info, err := ioutil.ReadFile(a.infoPath())
+	if err != nil {
+		t.Fatalf("can't read info file: %v\n", err)
+	}
+	mtime := time.Now().Add(time.Minute)
+	if err = os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatalf("can't change modification time: %v\n", err)
+	}
+	if fname, changed, err = a.DryRun(out, "test"); err != nil {
+		t.Fatalf("DryRun returned error: %v\n", err)
+	}
+	if changed || fname != putFname {
+		t.Fatalf("DryRun reported a change of an unchanged asset.")
+	}
+	if b, _ := ioutil.ReadFile(a.infoPath()); !bytes.Equal(b, info) {
+		t.Fatalf("DryRun changed the info file.")
+	}
+	// changed content keeps the old file
+	if err = ioutil.WriteFile(src, []byte("var c = 1;"), 0644); err != nil {
+		t.Fatalf("can't change input: %v\n", err)
+	}
+	if fname, changed, err = a.DryRun(out, "test"); err != nil {
+		t.Fatalf("DryRun returned error: %v\n", err)
+	}
+	if !changed || fname == putFname || exists(filepath.Join(out, fname)) {
+		t.Fatalf("DryRun didn't report the change, or wrote to disk.")
+	}
+	if !exists(filepath.Join(out, putFname)) {
+		t.Fatalf("DryRun deleted the old file.")
+	}
+	// compiler outputs are not cached
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "cat")
+	defer delete(Commands, "coffee")
+	cache := filepath.Join(dir, "cache")
+	a = New(src)
+	a.AddString("window.a = 1;", ".coffee")
+	a.SetCompress(false)
+	a.SetCompileCache(cache)
+	if _, _, err = a.DryRun(out, "test"); err != nil {
+		t.Fatalf("DryRun returned error: %v\n", err)
+	}
+	if exists(cache) {
+		t.Fatalf("DryRun wrote to the compile cache.")
+	}
+}
+
+func TestAddReader(t *testing.T) {
+	dir := tempFiles(t, "a.css")
+	defer os.RemoveAll(dir)
+
+	extra := "i{color:blue}"
+	a := New()
+	if err := a.AddReader(strings.NewReader(extra), ".css"); err != nil {
+		t.Fatalf("AddReader returned error: %v\n", err)
+	}
+	a.Add(filepath.Join(dir, "*.css"))
+	a.SetCompress(false)
+	fname, err := a.Put(filepath.Join(dir, outDir), "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(dir, outDir, fname))
+	if err != nil {
+		t.Fatalf("can't read output file: %v\n", err)
+	}
+	if expected := extra + "\n" + files["a.css"]; string(buf) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+	}
+}
+
+func TestAddString(t *testing.T) {
+	a := New()
+	a.AddString("window.a = 1;", ".js")
+	a.AddBytes([]byte("window.b = 2;"), ".js")
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "window.a = 1;\nwindow.b = 2;"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"js/c.js": &fstest.MapFile{Data: []byte(files["c.js"])},
+		"js/d.js": &fstest.MapFile{Data: []byte("window.d = 4;")},
+	}
+	a := New("js/*.js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := files["c.js"] + "\nwindow.d = 4;"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestSourceMaps(t *testing.T) {
+	dir := tempFiles(t, "a.coffee", "c.js")
+	defer os.RemoveAll(dir)
+
+	// a fake coffee that embeds a source map in its output
+	m := `{"version":3,"sources":["a.coffee"],"mappings":"AAAA"}`
+	script := "#!/bin/sh\ncat > /dev/null\necho 'window.a = 1;'\n" +
+		"echo '//# sourceMappingURL=data:application/json;base64," +
+		base64.StdEncoding.EncodeToString([]byte(m)) + "'\n"
+	coffee := filepath.Join(dir, "coffee")
+	if err := ioutil.WriteFile(coffee, []byte(script), 0755); err != nil {
+		t.Fatalf("can't create fake coffee: %v\n", err)
+	}
+	Commands["coffee"] = coffee
+	defer delete(Commands, "coffee")
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "a.coffee"))
+	a.SetCompress(false)
+	a.SetSourceMaps(true)
+	out := filepath.Join(dir, outDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(out, fname))
+	if err != nil {
+		t.Fatalf("can't read output file: %v\n", err)
+	}
+	expected := files["c.js"] + "\nwindow.a = 1;\n\n//# sourceMappingURL=" + fname + ".map\n"
+	if string(buf) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+	}
+	buf, err = ioutil.ReadFile(filepath.Join(out, fname+".map"))
+	if err != nil {
+		t.Fatalf("can't read source map: %v\n", err)
+	}
+	expected = `{"version":3,"file":"` + fname + `","sections":[` +
+		`{"offset":{"line":2,"column":0},"map":` + m + `}]}`
+	if string(buf) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+	}
+
+	// enabling source maps for an unchanged asset writes the source map
+	other := filepath.Join(dir, "other")
+	a = New(filepath.Join(dir, "c.js"), filepath.Join(dir, "a.coffee"))
+	a.SetCompress(false)
+	if fname, err = a.Put(other, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if exists(filepath.Join(other, fname+".map")) {
+		t.Fatalf("Put wrote a source map without SetSourceMaps.")
+	}
+	a.SetSourceMaps(true)
+	if fname, err = a.Put(other, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !exists(filepath.Join(other, fname+".map")) {
+		t.Fatalf("Put didn't write source map of unchanged asset.")
+	}
+
+	// source maps don't work with compression
+	a.SetCompress(true)
+	if _, _, err = a.Build(""); err != ErrCompressMap {
+		t.Fatalf("expected error %v, got %v\n", ErrCompressMap, err)
+	}
+}
+
+func TestMapFiles(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	m := `{"version":3,"sources":["lib.ts"],"mappings":"AAAA"}`
+	lib := filepath.Join(dir, "lib.js")
+	libMap := filepath.Join(dir, "lib.js.map")
+	if err := ioutil.WriteFile(lib, []byte("window.l = 1;\n//# sourceMappingURL=lib.js.map\n"), 0644); err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+	if err := ioutil.WriteFile(libMap, []byte(m), 0644); err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+	a := New(filepath.Join(dir, "c.js"), lib)
+	a.SetCompress(false)
+	a.SetMapFiles(map[string]string{lib: libMap})
+	out := filepath.Join(dir, outDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(out, fname))
+	if err != nil {
+		t.Fatalf("can't read output file: %v\n", err)
+	}
+	expected := files["c.js"] + "\nwindow.l = 1;\n\n//# sourceMappingURL=" + fname + ".map\n"
+	if string(buf) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+	}
+	buf, err = ioutil.ReadFile(filepath.Join(out, fname+".map"))
+	if err != nil {
+		t.Fatalf("can't read source map: %v\n", err)
+	}
+	expected = `{"version":3,"file":"` + fname + `","sections":[` +
+		`{"offset":{"line":2,"column":0},"map":` + m + `}]}`
+	if string(buf) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+	}
+
+	// changes of map files are noticed
+	m = `{"version":3,"sources":["lib.ts"],"mappings":"AACA"}`
+	if err := ioutil.WriteFile(libMap, []byte(m), 0644); err != nil {
+		t.Fatalf("can't change map file: %v\n", err)
+	}
+	mtime := time.Now().Add(time.Second)
+	os.Chtimes(libMap, mtime, mtime)
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached || !bytes.Contains(a.SourceMap(), []byte("AACA")) {
+		t.Fatalf("Put didn't notice the change of map file.")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := tempFiles(t, "a.css", "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	put := func(name, src string) string {
+		a := New(filepath.Join(dir, src))
+		a.SetCompress(false)
+		a.SetManifest(true)
+		fname, err := a.Put(out, name)
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		return fname
+	}
+	cssFname := put("app", "a.css")
+	jsFname := put("app", "c.js")
+	// a stale entry that should be pruned
+	os.Remove(filepath.Join(out, cssFname))
+	otherFname := put("other", "c.js")
+
+	buf, err := ioutil.ReadFile(filepath.Join(out, ManifestFname))
+	if err != nil {
+		t.Fatalf("can't read manifest: %v\n", err)
+	}
+	var m map[string]string
+	if err = json.Unmarshal(buf, &m); err != nil {
+		t.Fatalf("can't parse manifest: %v\n", err)
+	}
+	expected := map[string]string{"app.js": jsFname, "other.js": otherFname}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, m)
+	}
+}
+
+func TestConcurrentPut(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	var wg sync.WaitGroup
+	fnames := make([]string, 20)
+	for i := range fnames {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a := New()
+			a.AddString(fmt.Sprintf("window.a = %d;", i), ".js")
+			a.SetCompress(false)
+			a.SetManifest(true)
+			fname, err := a.Put(out, fmt.Sprintf("a%d", i))
+			if err != nil {
+				t.Errorf("Put returned error: %v\n", err)
+			}
+			fnames[i] = fname
+		}(i)
+	}
+	wg.Wait()
+
+	buf, err := ioutil.ReadFile(filepath.Join(out, ManifestFname))
+	if err != nil {
+		t.Fatalf("can't read manifest: %v\n", err)
+	}
+	var m map[string]string
+	if err = json.Unmarshal(buf, &m); err != nil {
+		t.Fatalf("can't parse manifest: %v\n", err)
+	}
+	for i, fname := range fnames {
+		if key := fmt.Sprintf("a%d.js", i); m[key] != fname {
+			t.Fatalf("expected: %s\ngot: %s\n", fname, m[key])
+		}
+	}
+}
+
+func TestGzip(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetGzip(true)
+	out := filepath.Join(dir, outDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	f, err := os.Open(filepath.Join(out, fname+".gz"))
+	if err != nil {
+		t.Fatalf("can't open gzipped file: %v\n", err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("can't read gzipped file: %v\n", err)
+	}
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("can't read gzipped file: %v\n", err)
+	}
+	if string(buf) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(buf))
+	}
+
+	// gzipped file is removed with the old file
+	a = New(filepath.Join(dir, "c.js"))
+	a.AddString("window.d = 4;", ".js")
+	a.SetCompress(false)
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if exists(filepath.Join(out, fname+".gz")) {
+		t.Fatalf("Put failed to remove old file \"%s.gz\".", fname)
+	}
+
+	// enabling gzip for an unchanged asset writes the gzipped file
+	a.SetGzip(true)
+	if fname, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !exists(filepath.Join(out, fname+".gz")) {
+		t.Fatalf("Put didn't write \"%s.gz\".", fname)
+	}
+}
+
+func TestBrotli(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetGzip(true)
+	a.SetBrotli(true)
+	out := filepath.Join(dir, outDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !exists(filepath.Join(out, fname+".gz")) {
+		t.Fatalf("Put didn't write \"%s.gz\".", fname)
+	}
+	f, err := os.Open(filepath.Join(out, fname+".br"))
+	if err != nil {
+		t.Fatalf("can't open Brotli compressed file: %v\n", err)
+	}
+	defer f.Close()
+	buf, err := ioutil.ReadAll(brotli.NewReader(f))
+	if err != nil {
+		t.Fatalf("can't read Brotli compressed file: %v\n", err)
+	}
+	if string(buf) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(buf))
+	}
+	// enabling Brotli for an unchanged asset writes the compressed file
+	a = New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	out = filepath.Join(dir, "other")
+	if fname, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	a.SetBrotli(true)
+	if fname, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !exists(filepath.Join(out, fname+".br")) {
+		t.Fatalf("Put didn't write \"%s.br\".", fname)
+	}
+}
+
+func TestHash(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if err := a.SetHash("sha256"); err != nil {
+		t.Fatalf("SetHash returned error: %v\n", err)
+	}
+	out := filepath.Join(dir, outDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	sum, _ := hash([]byte(files["c.js"]), crypto.SHA256)
+	if expected := sum + ".js"; fname != expected {
+		t.Fatalf("expected name %s, got %s\n", expected, fname)
+	}
+
+	// changing the algorithm is a change
+	a.SetHash("md5")
+	if fname, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	sum, _ = hash([]byte(files["c.js"]), crypto.MD5)
+	if expected := sum + ".js"; fname != expected {
+		t.Fatalf("expected name %s, got %s\n", expected, fname)
+	}
+
+	if err = a.SetHash("crc32"); err == nil {
+		t.Fatalf("SetHash accepted unsupported algorithm.")
+	}
+}
+
+func TestHashLength(t *testing.T) {
+	a := New()
+	a.AddString(files["c.js"], ".js")
+	a.SetCompress(false)
+	if err := a.SetHashLength(5); err == nil {
+		t.Fatalf("SetHashLength accepted a too short length.")
+	}
+	if err := a.SetHashLength(8); err != nil {
+		t.Fatalf("SetHashLength returned error: %v\n", err)
+	}
+	_, fname, err := a.Build("test")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	sum, _ := hash([]byte(files["c.js"]), crypto.MD5)
+	if expected := "test-" + sum[:8] + ".js"; fname != expected {
+		t.Fatalf("expected name %s, got %s\n", expected, fname)
+	}
+	// changing the length renames the final file of an unchanged asset
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	a.SetHashLength(0)
+	if fname, err = a.Put(dir, "test"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	a.SetHashLength(8)
+	if fname, err = a.Put(dir, "test"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if expected := "test-" + sum[:8] + ".js"; fname != expected {
+		t.Fatalf("expected name %s, got %s\n", expected, fname)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if _, err := a.Integrity(); err != ErrNotBuilt {
+		t.Fatalf("expected error %v, got %v\n", ErrNotBuilt, err)
+	}
+	sum := sha512.Sum384([]byte(files["c.js"]))
+	expected := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	// first Put builds the asset, and second one finds it unchanged
+	for i := 0; i < 2; i++ {
+		if _, err := a.Put(filepath.Join(dir, outDir), ""); err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		integrity, err := a.Integrity()
+		if err != nil {
+			t.Fatalf("Integrity returned error: %v\n", err)
+		}
+		if integrity != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, integrity)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	defer func(d time.Duration) { WatchInterval = d }(WatchInterval)
+	WatchInterval = 10 * time.Millisecond
+
+	a := New(filepath.Join(dir, "*.js"))
+	a.SetCompress(false)
+	out := filepath.Join(dir, outDir)
+	w, err := a.Watch(out, "")
+	if err != nil {
+		t.Fatalf("Watch returned error: %v\n", err)
+	}
+	defer w.Close()
+
+	// add a new file that matches the glob
+	src := filepath.Join(dir, "d.js")
+	if err = ioutil.WriteFile(src, []byte("window.d = 4;"), 0644); err != nil {
+		t.Fatalf("can't create test file \"%s\": %v\n", src, err)
+	}
+	select {
+	case fname := <-w.Names:
+		buf, err := ioutil.ReadFile(filepath.Join(out, fname))
+		if err != nil {
+			t.Fatalf("can't read output file: %v\n", err)
+		}
+		if expected := files["c.js"] + "\nwindow.d = 4;"; string(buf) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, string(buf))
+		}
+	case err = <-w.Errors:
+		t.Fatalf("watcher returned error: %v\n", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher didn't rebuild the asset.")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetLogger(log.New(&buf, "", 0))
+	out := filepath.Join(dir, outDir)
+	for i := 0; i < 2; i++ {
+		if _, err := a.Put(out, "test"); err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+	}
+	fname := a.fname
+	expected := "assets: wrote " + filepath.Join(out, fname) + "\n" +
+		"assets: " + fname + " is unchanged\n"
+	if buf.String() != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, buf.String())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := tempFiles(t, "a.css", "b.less", "c.js")
+	defer os.RemoveAll(dir)
+	// compilers would fail if they were run
+	Commands["lessc"] = "false"
+	defer delete(Commands, "lessc")
+
+	tests := []struct {
+		names []string
+		err   string
+	}{
+		{[]string{"a.css", "b.less"}, ""},
+		{[]string{"a.css", "c.js"}, ErrMix.Error()},
+		{[]string{"*.txt"}, ErrNoInput.Error()},
+		{[]string{"*.css", "*.md"}, ""},
+	}
+	for _, test := range tests {
+		a := New()
+		for _, name := range test.names {
+			a.Add(filepath.Join(dir, name))
+		}
+		err := a.Validate()
+		if (err == nil && len(test.err) > 0) || (err != nil && err.Error() != test.err) {
+			t.Fatalf("expected error: %s\ngot: %v\n", test.err, err)
+		}
+	}
+	a := New()
+	a.AddString("body {}", ".txt")
+	if err := a.Validate(); err == nil || !strings.Contains(err.Error(), "unsupported extension") {
+		t.Fatalf("expected error of unsupported extension\ngot: %v\n", err)
+	}
+}
+
+func TestMix(t *testing.T) {
+	dir := tempFiles(t, "a.css", "c.js")
+	defer os.RemoveAll(dir)
+
+	// coffee would fail if it was run
+	Commands["coffee"] = "false"
+	defer delete(Commands, "coffee")
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "a.css"))
+	a.AddString("window.a = 1", ".coffee")
+	if _, err := a.Put(filepath.Join(dir, outDir), ""); err != ErrMix {
+		t.Fatalf("expected error %v, got %v\n", ErrMix, err)
+	}
+}
+
+func TestMixKeepsOldFile(t *testing.T) {
+	dir := tempFiles(t, "a.css", "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	// the same asset, mixed with CSS, fails without touching the old file
+	a.Add(filepath.Join(dir, "a.css"))
+	if _, err = a.Put(out, "app"); err != ErrMix {
+		t.Fatalf("expected error %v, got %v\n", ErrMix, err)
+	}
+	if !exists(filepath.Join(out, fname)) {
+		t.Fatalf("mixed asset deleted the old file")
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "cat")
+	defer delete(Commands, "coffee")
+
+	a := New()
+	for i := 0; i < 10; i++ {
+		a.AddString(fmt.Sprintf("window.a%d = %d;", i, i), ".coffee")
+		a.AddString("window.b = 1;", ".js")
+	}
+	a.SetCompress(false)
+	a.SetConcurrency(4)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	var l []string
+	for i := 0; i < 10; i++ {
+		l = append(l, fmt.Sprintf("window.a%d = %d;", i, i), "window.b = 1;")
+	}
+	expected := strings.Join(l, "\n")
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+
+	// errors are returned
+	Commands["coffee"] = "false"
+	if _, _, err = a.Build(""); err == nil {
+		t.Fatalf("Build didn't return error of failed compilation.")
+	}
+}
+
+func BenchmarkHashInputs(b *testing.B) {
+	// a large vendor bundle on disk, which is hashed by reading it into memory, or by
+	// streaming it
+	dir := tempFiles(b)
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "vendor.js")
+	content := bytes.Repeat([]byte("window.v = function(){return 1;};\r\n"), 1<<20)
+	if err := ioutil.WriteFile(fname, content, 0644); err != nil {
+		b.Fatalf("can't create test file: %v\n", err)
+	}
+	a := New(fname)
+	if err := a.prepare(); err != nil {
+		b.Fatalf("prepare returned error: %v\n", err)
+	}
+	inputs := a.inputs
+	b.Run("load", func(b *testing.B) {
+		b.SetBytes(int64(len(content)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a.inputs = append([]input{}, inputs...)
+			a.hashes = nil
+			if err := a.load(); err != nil {
+				b.Fatalf("load returned error: %v\n", err)
+			}
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		b.SetBytes(int64(len(content)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := a.streamHash(inputs[0]); err != nil {
+				b.Fatalf("streamHash returned error: %v\n", err)
+			}
+		}
+	})
+}
+
+func BenchmarkCompileSerial(b *testing.B) {
+	benchmarkCompile(b, 1)
+}
+
+func BenchmarkCompileParallel(b *testing.B) {
+	benchmarkCompile(b, 8)
+}
+
+// benchmarkCompile compiles 16 CoffeeScript inputs with a slow fake coffee.
+func benchmarkCompile(b *testing.B, concurrency int) {
+	dir := tempFiles(b)
+	defer os.RemoveAll(dir)
+	Commands["coffee"] = fakeTool(b, dir, "coffee", "sleep 0.01; cat")
+	defer delete(Commands, "coffee")
+
+	a := New()
+	for i := 0; i < 16; i++ {
+		a.AddString("window.a = 1;", ".coffee")
+		a.AddString("window.b = 1;", ".js")
+	}
+	a.SetCompress(false)
+	a.SetConcurrency(concurrency)
+	for i := 0; i < b.N; i++ {
+		if _, _, err := a.Build(""); err != nil {
+			b.Fatalf("Build returned error: %v\n", err)
+		}
+	}
+}
+
+func TestKeepOld(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetKeepOld(true)
+	oldFname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	a.AddString("window.d = 4;", ".js")
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if fname == oldFname {
+		t.Fatalf("Put didn't notice the change.")
+	}
+	if !exists(filepath.Join(out, oldFname)) {
+		t.Fatalf("Put removed old file \"%s\".", oldFname)
+	}
+}
+
+func TestModes(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetFileMode(0600)
+	a.SetDirMode(0700)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	modes := []struct {
+		fname string
+		mode  os.FileMode
+	}{
+		{out, os.ModeDir | 0700},
+		{filepath.Join(out, fname), 0600},
+		{a.infoPath(), 0600},
+	}
+	for _, m := range modes {
+		info, err := os.Stat(m.fname)
+		if err != nil {
+			t.Fatalf("can't stat \"%s\": %v\n", m.fname, err)
+		}
+		if info.Mode() != m.mode {
+			t.Fatalf("expected: %v\ngot: %v\n", m.mode, info.Mode())
+		}
+	}
+}
+
+func TestLegacyInfo(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	// replace info with a legacy one that points to another file
+	old := filepath.Join(out, "old.js")
+	if err := ioutil.WriteFile(old, nil, 0644); err != nil {
+		t.Fatalf("can't create old file: %v\n", err)
+	}
+	legacy := "old.js\n" + strings.Join(a.hashes, "\n")
+	if err := ioutil.WriteFile(a.infoPath(), []byte(legacy), 0644); err != nil {
+		t.Fatalf("can't write legacy info: %v\n", err)
+	}
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached {
+		t.Fatalf("Put didn't rebuild after legacy info.")
+	}
+	if exists(old) {
+		t.Fatalf("Put didn't remove old file.")
+	}
+	buf, err := ioutil.ReadFile(a.infoPath())
+	if err != nil {
+		t.Fatalf("can't read info: %v\n", err)
+	}
+	var inf info
+	if err = json.Unmarshal(buf, &inf); err != nil {
+		t.Fatalf("info is not JSON: %v\n", err)
+	}
+	if inf.Version != infoVersion || inf.Fname != fname {
+		t.Fatalf("expected: %s\ngot: %s\n", fname, inf.Fname)
+	}
+}
+
+func TestInfoDir(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	infoDir := filepath.Join(dir, "cache")
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetInfoDir(infoDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !exists(filepath.Join(infoDir, a.infoFname())) {
+		t.Fatalf("Put didn't save info in info directory.")
+	}
+	if exists(filepath.Join(out, a.infoFname())) {
+		t.Fatalf("Put saved info in output directory.")
+	}
+	// info is found in the info directory
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !a.cached || a.fname != fname {
+		t.Fatalf("Put didn't use info in info directory.")
+	}
+}
+
+func TestModTime(t *testing.T) {
+	dir := tempFiles(t, "e.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	src := filepath.Join(dir, "e.js")
+	a := New(src)
+	a.SetCompress(false)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("can't stat input: %v\n", err)
+	}
+	mtime := info.ModTime()
+	// same size and modification time, so the file shouldn't be read
+	if err = ioutil.WriteFile(src, []byte("var e = 6"), 0644); err != nil {
+		t.Fatalf("can't change input: %v\n", err)
+	}
+	if err = os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatalf("can't change modification time: %v\n", err)
+	}
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !a.cached || a.hashes != nil {
+		t.Fatalf("Put read input with the same modification time.")
+	}
+	// another modification time makes Put check the content
+	mtime = mtime.Add(time.Second)
+	if err = os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatalf("can't change modification time: %v\n", err)
+	}
+	newFname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached || newFname == fname {
+		t.Fatalf("Put didn't notice the change.")
+	}
+}
+
+func TestClean(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetGzip(true)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if err = Clean(out, "app", ".js"); err != nil {
+		t.Fatalf("Clean returned error: %v\n", err)
+	}
+	for _, f := range []string{fname, fname + ".gz", a.infoFname()} {
+		if exists(filepath.Join(out, f)) {
+			t.Fatalf("Clean didn't remove \"%s\".", f)
+		}
+	}
+	// nothing to clean
+	if err = Clean(out, "app", ".js"); err != nil {
+		t.Fatalf("Clean returned error: %v\n", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetFingerprint(false)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if fname != "app.js" {
+		t.Fatalf("expected: app.js\ngot: %s\n", fname)
+	}
+	// changes are still detected, and the file is replaced
+	a.AddString("window.d = 4;", ".js")
+	if fname, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached || fname != "app.js" {
+		t.Fatalf("Put didn't rebuild \"%s\".", fname)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(out, fname))
+	if err != nil {
+		t.Fatalf("can't read final file: %v\n", err)
+	}
+	if !strings.HasSuffix(string(b), "window.d = 4;") {
+		t.Fatalf("final file isn't updated: %s\n", string(b))
+	}
+}
+
+func TestTag(t *testing.T) {
+	a := New()
+	if _, err := a.Tag("/static"); err != ErrNotBuilt {
+		t.Fatalf("expected: %v\ngot: %v\n", ErrNotBuilt, err)
+	}
+	tests := []struct {
+		content, ext, prefix, format string
+	}{
+		{files["c.js"], ".js", "/static/", `<script src="/static/%s" integrity="%s"></script>`},
+		{files["a.css"], ".css", "https://cdn.example.com", `<link rel="stylesheet" href="https://cdn.example.com/%s" integrity="%s">`},
+	}
+	for _, test := range tests {
+		a := New()
+		a.AddString(test.content, test.ext)
+		a.SetCompress(false)
+		_, fname, err := a.Build("app")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		integrity, _ := a.Integrity()
+		tag, err := a.Tag(test.prefix)
+		if err != nil {
+			t.Fatalf("Tag returned error: %v\n", err)
+		}
+		if expected := fmt.Sprintf(test.format, fname, integrity); string(tag) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, tag)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	for _, name := range []string{"../x", "a/b", `a\b`, ".."} {
+		if _, err := a.Put(out, name); err != ErrName {
+			t.Fatalf("expected: %v\ngot: %v\n", ErrName, err)
+		}
+	}
+	for _, name := range []string{"", "app", "app.min"} {
+		if _, err := a.Put(out, name); err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if p := a.OutputPath(); p != "" {
+		t.Fatalf("expected empty path before Put\ngot: %s\n", p)
+	}
+	for i := 0; i < 2; i++ {
+		fname, err := a.Put(out, "app")
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		if expected := filepath.Join(out, fname); a.OutputPath() != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, a.OutputPath())
+		}
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	dir := tempFiles(t, "e.js", "f.js")
+	defer os.RemoveAll(dir)
+
+	// without a separator, second file would call the number 5
+	a := New(filepath.Join(dir, "e.js"), filepath.Join(dir, "f.js"))
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := files["e.js"] + "\n" + files["f.js"]; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestSetSeparator(t *testing.T) {
+	dir := tempFiles(t, "e.js", "f.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "e.js"), filepath.Join(dir, "f.js"))
+	a.SetCompress(false)
+	a.SetSeparator([]byte(";\n\n"))
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := files["e.js"] + ";\n\n" + files["f.js"]; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	dir := tempFiles(t, "a.coffee")
+	defer os.RemoveAll(dir)
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "cat")
+	defer delete(Commands, "coffee")
+	crlf := strings.Replace(files["a.coffee"], "\n", "\r\n", -1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "crlf.coffee"), []byte(crlf), 0644); err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+
+	build := func(fname string) (hashes []string, b []byte) {
+		a := New(filepath.Join(dir, fname))
+		a.SetCompress(false)
+		b, _, err := a.Build("")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		return a.hashes, b
+	}
+	lfHashes, _ := build("a.coffee")
+	crlfHashes, b := build("crlf.coffee")
+	if string(b) != files["a.coffee"] {
+		t.Fatalf("expected: %q\ngot: %q\n", files["a.coffee"], string(b))
+	}
+	// line endings alone are not a change
+	if !reflect.DeepEqual(lfHashes, crlfHashes) {
+		t.Fatalf("expected: %v\ngot: %v\n", lfHashes, crlfHashes)
+	}
+}
+
+func TestStreamHash(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	long := strings.Repeat("window.v = 1;\r\n", 5000)
+	for _, content := range []string{"", "window.a = 1;\n", "a\r\nb\r\n", "a\rb\r", "a\r\r\n",
+		long, long[:sniffLen-1] + "\r\n" + long, long[:32*1024+sniffLen-1] + "\r\n"} {
+		fname := filepath.Join(dir, "a.js")
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("can't create test file: %v\n", err)
+		}
+		a := New(fname)
+		sum, ok, err := a.streamHash(input{fname: fname, ext: ".js"})
+		if err != nil || !ok {
+			t.Fatalf("streamHash returned %v, %v\n", ok, err)
+		}
+		// the same as the hash of content that's read by readInputs
+		expected := ""
+		if len(content) > 0 {
+			expected, _ = hash([]byte(strings.Replace(content, "\r\n", "\n", -1)), crypto.MD5)
+		}
+		if sum != expected {
+			t.Fatalf("expected: %s\ngot: %s\nfor: %q\n", expected, sum, content)
+		}
+	}
+
+	// unchanged content of touched inputs is not read into memory
+	fname := filepath.Join(dir, "b.js")
+	if err := ioutil.WriteFile(fname, []byte("window.b = 1;\r\n"), 0644); err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+	out := filepath.Join(dir, outDir)
+	a := New(fname)
+	a.SetCompress(false)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fname, later, later); err != nil {
+		t.Fatalf("can't change time of input: %v\n", err)
+	}
+	a = New(fname)
+	a.SetCompress(false)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !a.cached || a.inputs[0].bytes != nil {
+		t.Fatalf("expected: unchanged asset without reading inputs\ngot: cached: %v, "+
+			"read: %v\n", a.cached, a.inputs[0].bytes != nil)
+	}
+}
+
+func TestLFWriter(t *testing.T) {
+	content := "a\r\nb\rc\r\r\nd\r"
+	expected := "a\nb\rc\r\nd\r"
+	// content is written in two parts, split at every place
+	for i := 0; i <= len(content); i++ {
+		var buf bytes.Buffer
+		w := &lfWriter{w: &buf}
+		w.Write([]byte(content[:i]))
+		w.Write([]byte(content[i:]))
+		w.Flush()
+		if buf.String() != expected {
+			t.Fatalf("expected: %q\ngot: %q\nsplit at %d\n", expected, buf.String(), i)
+		}
+	}
+}
+
+func TestBanner(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2013, 2, 1, 0, 0, 0, 0, time.UTC) }
+
+	a := New()
+	a.AddString(files["c.js"], ".js")
+	a.SetCompress(false)
+	a.SetBanner("(c) {{date}} Someone")
+	b, fname, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := "/* (c) 2013-02-01 Someone */\n" + files["c.js"]
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	// banner is part of the hash
+	sum, _ := hash([]byte(expected), crypto.MD5)
+	if fname != sum+".js" {
+		t.Fatalf("expected name %s, got %s\n", sum+".js", fname)
+	}
+
+	a.SetBanner("line 1\nline 2")
+	if b, _, err = a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected = "/*\n * line 1\n * line 2\n */\n" + files["c.js"]
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+
+	// changing the banner is a change
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	oldFname, err := a.Put(dir, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	a.SetBanner("another banner")
+	if fname, err = a.Put(dir, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if fname == oldFname {
+		t.Fatalf("Put didn't notice the change of banner.")
+	}
+}
+
+func TestUncompressedBanner(t *testing.T) {
+	dir := tempFiles(t, "e.js", "f.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2013, 2, 1, 0, 0, 0, 0, time.UTC) }
+
+	// third-party code is shipped as it is, with attribution on top
+	a := New(filepath.Join(dir, "e.js"), filepath.Join(dir, "f.js"))
+	a.SetCompress(false)
+	a.SetBanner("lib by Someone, MIT License")
+	fname, err := a.Put(out, "lib")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(out, fname))
+	expected := "/* lib by Someone, MIT License */\n" + files["e.js"] + "\n" + files["f.js"]
+	if string(b) != expected {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, b)
+	}
+	sum, _ := hash([]byte(expected), crypto.MD5)
+	if expected := "lib-" + sum + ".js"; fname != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, fname)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if a.Bytes() != nil || a.ContentHash() != "" {
+		t.Fatalf("Bytes and ContentHash returned values before build.")
+	}
+	fname, err := a.Put(filepath.Join(dir, outDir), "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if string(a.Bytes()) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(a.Bytes()))
+	}
+	if expected := a.ContentHash() + ".js"; fname != expected {
+		t.Fatalf("expected name %s, got %s\n", expected, fname)
+	}
+}
+
+func TestRecursiveGlob(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	srcs := map[string]string{
+		"js/b.js":          "window.b = 2;",
+		"js/lib/a.js":      "window.a = 1;",
+		"js/lib/deep/c.js": "window.c = 3;",
+		"js/lib/d.css":     "d{}",
+	}
+	for name, content := range srcs {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("can't create test file \"%s\": %v\n", name, err)
+		}
+	}
+
+	a := New(filepath.Join(dir, "js", "**", "*.js"))
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := "window.b = 2;\nwindow.a = 1;\nwindow.c = 3;"
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+
+	// same from a filesystem
+	fsys := fstest.MapFS{}
+	for name, content := range srcs {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	a = New("js/lib/**")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	if _, _, err = a.Build(""); err != ErrMix {
+		t.Fatalf("expected error %v, got %v\n", ErrMix, err)
+	}
+	a = New("**/deep/*.js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	if b, _, err = a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected = "window.c = 3;"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestGlobOrder(t *testing.T) {
+	// filepath.Glob sorts directories, so it gives "a/x.js" before "a-b/x.js"
+	fsys := fstest.MapFS{
+		"a/x.js":   &fstest.MapFile{Data: []byte("window.a = 1;")},
+		"a-b/x.js": &fstest.MapFile{Data: []byte("window.b = 2;")},
+	}
+	a := New("*/x.js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "window.b = 2;\nwindow.a = 1;"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestBaseDir(t *testing.T) {
+	dir := tempFiles(t, "c.js", "e.js", "f.js")
+	defer os.RemoveAll(dir)
+	other := tempFiles(t, "a.coffee")
+	defer os.RemoveAll(other)
+	Commands["coffee"] = fakeTool(t, other, "coffee", "cat")
+	defer delete(Commands, "coffee")
+
+	a := New("*.js", filepath.Join(other, "a.coffee"))
+	a.SetBaseDir(dir)
+	a.Ignore("./f.js")
+	a.SetCompress(false)
+	if _, _, err := a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := []string{filepath.Join(dir, "c.js"), filepath.Join(dir, "e.js"),
+		filepath.Join(other, "a.coffee")}
+	if !reflect.DeepEqual(a.Inputs(), expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, a.Inputs())
+	}
+
+	// names in a filesystem
+	fsys := fstest.MapFS{"assets/js/a.js": &fstest.MapFile{Data: []byte("window.a = 1;")}}
+	a = New("js/*.js")
+	a.SetFS(fsys)
+	a.SetBaseDir("assets")
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if string(b) != "window.a = 1;" {
+		t.Fatalf("expected: window.a = 1;\ngot: %s\n", string(b))
+	}
+}
+
+func TestPriority(t *testing.T) {
+	fsys := fstest.MapFS{
+		"js/app.js":        &fstest.MapFile{Data: []byte("app();")},
+		"js/lib/jquery.js": &fstest.MapFile{Data: []byte("jquery();")},
+		"js/plugin.js":     &fstest.MapFile{Data: []byte("plugin();")},
+		"js/zepto.js":      &fstest.MapFile{Data: []byte("zepto();")},
+	}
+	a := New("js/*.js", "js/lib/*.js")
+	a.AddString("inline();", ".js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	a.SetPriority(map[string]int{"jquery.js": -1, "js/zepto.js": -1, "app.js": 1})
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "zepto();\njquery();\nplugin();\ninline();\napp();"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	fsys := fstest.MapFS{
+		"js/a.js":          &fstest.MapFile{Data: []byte("window.a = 1;")},
+		"js/b.js":          &fstest.MapFile{Data: []byte("window.b = 2;")},
+		"js/b.min.js":      &fstest.MapFile{Data: []byte("window.b=2;")},
+		"js/vendor/c.js":   &fstest.MapFile{Data: []byte("window.c = 3;")},
+		"js/vendor/d/e.js": &fstest.MapFile{Data: []byte("window.e = 5;")},
+	}
+	a := New("js/*.js", "js/vendor/**/*.js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	a.Ignore("*.min.js", "js/vendor/d/**")
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "window.a = 1;\nwindow.b = 2;\nwindow.c = 3;"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestIgnoreFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"js/.assetsignore": &fstest.MapFile{Data: []byte("# minified copies\n*.min.js\n\nvendor/d/**\n")},
+		"js/a.js":          &fstest.MapFile{Data: []byte("window.a = 1;")},
+		"js/a.min.js":      &fstest.MapFile{Data: []byte("window.a=1;")},
+		"js/b.js":          &fstest.MapFile{Data: []byte("window.b = 2;")},
+		"js/vendor/c.js":   &fstest.MapFile{Data: []byte("window.c = 3;")},
+		"js/vendor/d/e.js": &fstest.MapFile{Data: []byte("window.e = 5;")},
+	}
+	a := New("js/**/*.js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	a.Ignore("b.js")
+	a.SetIgnoreFile("js/.assetsignore")
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "window.a = 1;\nwindow.c = 3;"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	// missing ignore file is an error
+	a.SetIgnoreFile("js/.missing")
+	if _, _, err = a.Build(""); err == nil {
+		t.Fatalf("Build didn't return error of missing ignore file.")
+	}
+}
+
+func TestPreserveLicense(t *testing.T) {
+	a := New()
+	a.AddString("/*! Lib v1 | MIT License */\nvar a = 1; // one", ".js")
+	a.AddString("/* not a license */\n/*!\n * App\n */\nvar b = 2;", ".js")
+	a.SetJSCompressor("native")
+	a.SetPreserveLicense(true)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := "/*! Lib v1 | MIT License */\n/*!\n * App\n */\nvar a=1;var b=2;"
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestLicenseFile(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New()
+	a.AddString("/*! Lib v1 | MIT License */\nvar a = 1;", ".js")
+	a.AddString("/*! App */\nvar b = 2;", ".js")
+	a.SetJSCompressor("native")
+	a.SetLicenseFile(true)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	lfname := strings.TrimSuffix(fname, ".js") + ".LICENSE.txt"
+	b, err := ioutil.ReadFile(filepath.Join(out, lfname))
+	if err != nil {
+		t.Fatalf("can't read license file: %v\n", err)
+	}
+	if expected := "/*! Lib v1 | MIT License */\n/*! App */\n"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	// license file is deleted with the final file
+	a.AddString("var c = 3;", ".js")
+	if _, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if exists(filepath.Join(out, lfname)) {
+		t.Fatalf("old license file is not deleted")
+	}
+}
+
+func TestStrictGlobs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"js/a.js": &fstest.MapFile{Data: []byte("window.a = 1;")},
+	}
+	a := New("js/*.js", "js/scrpts/*.js")
+	a.SetFS(fsys)
+	a.SetCompress(false)
+	if _, _, err := a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	a.SetStrictGlobs(true)
+	_, _, err := a.Build("")
+	if err == nil || !strings.Contains(err.Error(), "js/scrpts/*.js") {
+		t.Fatalf("expected error about js/scrpts/*.js\ngot: %v\n", err)
+	}
+}
+
+func TestNativeCSSCompressor(t *testing.T) {
+	a := New()
+	a.AddString(files["a.css"], ".css")
+	a.AddString("b {\n\tcolor: #444;\n}", ".css")
+	a.SetCSSCompressor("native")
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "body{color:red}b{color:#444}"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestCompressPerExtension(t *testing.T) {
+	build := func(content, ext string) string {
+		a := New()
+		a.AddString(content, ext)
+		a.SetCSSCompressor("native")
+		a.SetJSCompressor("native")
+		a.SetCompressJS(false)
+		b, _, err := a.Build("")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		return string(b)
+	}
+	if expected, got := "body{color:red}", build(files["a.css"], ".css"); got != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	if expected, got := files["c.js"], build(files["c.js"], ".js"); got != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+}
+
+func TestBabel(t *testing.T) {
+	dir := tempFiles(t, "c.js", "e.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	Commands["babel"] = fakeTool(t, dir, "babel", `sed 's/^/"use strict";/'`)
+	defer delete(Commands, "babel")
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "e.js"))
+	a.SetCompress(false)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	// enabling babel for an unchanged asset transpiles each input
+	a.SetBabel(true)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(out, fname))
+	expected := `"use strict";` + files["c.js"] + "\n" + `"use strict";` + files["e.js"]
+	if string(b) != expected {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, b)
+	}
+
+	// outputs of babel are kept in the compile cache
+	runs := filepath.Join(dir, "runs")
+	Commands["babel"] = fakeTool(t, dir, "counted-babel", "echo >> "+runs+"; cat")
+	a.SetCompileCache(filepath.Join(dir, "cache"))
+	for i := 0; i < 2; i++ {
+		if _, _, err = a.Build(""); err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+	}
+	if b, _ = ioutil.ReadFile(runs); len(b) != 2 {
+		t.Fatalf("expected: 2 runs of babel, one for each input\ngot: %d\n", len(b))
+	}
+}
+
+func TestCompressChange(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	Commands["terser"] = fakeTool(t, dir, "terser", "cat > /dev/null; echo terser")
+	defer delete(Commands, "terser")
+
+	// each change of compression rebuilds the unchanged asset
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	a.SetCompressJS(false)
+	minified, _ := minifyJS([]byte(files["c.js"]))
+	for _, step := range []struct {
+		change   func()
+		expected string
+	}{
+		{func() {}, files["c.js"]},
+		{func() { a.SetCompressJS(true) }, string(minified)},
+		{func() { a.SetJSCompressor("terser") }, "terser\n"},
+	} {
+		step.change()
+		fname, err := a.Put(out, "")
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		if b, _ := ioutil.ReadFile(filepath.Join(out, fname)); string(b) != step.expected {
+			t.Fatalf("expected: %q\ngot: %q\n", step.expected, b)
+		}
+	}
+}
+
+func TestNativeJSCompressor(t *testing.T) {
+	a := New()
+	a.AddString("// first\nvar a = 1;", ".js")
+	a.AddString("/* second */\nfunction f ( x ) {\n  return x + a;\n}", ".js")
+	a.SetJSCompressor("native")
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "var a=1;function f(x){return x+a;}"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestAutoprefix(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["postcss"] = fakeTool(t, dir, "postcss",
+		`test "$*" = "--use autoprefixer" || exit 1; sed 's/user-select/-webkit-user-select: none; user-select/'`)
+	defer delete(Commands, "postcss")
+
+	a := New()
+	a.AddString("a { user-select: none; }", ".css")
+	a.SetCompress(false)
+	a.SetAutoprefix(true)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "a { -webkit-user-select: none; user-select: none; }"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+
+	// JS is left alone
+	Commands["postcss"] = fakeTool(t, dir, "postcss", "exit 1")
+	a = New()
+	a.AddString(files["c.js"], ".js")
+	a.SetCompress(false)
+	a.SetAutoprefix(true)
+	if b, _, err = a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if string(b) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(b))
+	}
+}
+
+func TestDedup(t *testing.T) {
+	dir := tempFiles(t, "c.js", "e.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "e.js"), filepath.Join(dir, "*.js"))
+	a.SetCompress(false)
+	a.SetDedup(true)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := files["c.js"] + "\n" + files["e.js"]; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestEmptyInputs(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "echo called >&2; exit 1")
+	defer delete(Commands, "coffee")
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty.coffee"), nil, 0644); err != nil {
+		t.Fatalf("can't create empty file: %v\n", err)
+	}
+
+	a := New(filepath.Join(dir, "empty.coffee"), filepath.Join(dir, "c.js"))
+	a.AddString("", ".coffee")
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if string(b) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(b))
+	}
+
+	a = New(filepath.Join(dir, "empty.coffee"))
+	if _, err = a.Put(filepath.Join(dir, outDir), ""); err != ErrNoInput {
+		t.Fatalf("expected: %v\ngot: %v\n", ErrNoInput, err)
+	}
+}
+
+func TestInputs(t *testing.T) {
+	dir := tempFiles(t, "c.js", "e.js", "f.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "*.js"))
+	a.AddString("var g;", ".js")
+	a.Ignore(filepath.Join(dir, "e.js"))
+	a.SetCompress(false)
+	if a.Inputs() != nil {
+		t.Fatalf("Inputs returned names before build.")
+	}
+	if _, err := a.Put(filepath.Join(dir, outDir), ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	expected := []string{filepath.Join(dir, "c.js"), filepath.Join(dir, "f.js"), "<bytes:js>"}
+	if !reflect.DeepEqual(a.Inputs(), expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, a.Inputs())
+	}
+}
+
+func TestChangedInputs(t *testing.T) {
+	dir := tempFiles(t, "c.js", "e.js", "f.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "e.js"))
+	a.SetCompress(false)
+	put := func(expected ...string) {
+		if _, err := a.Put(out, ""); err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		if changed := a.ChangedInputs(); !reflect.DeepEqual(changed, expected) {
+			t.Fatalf("expected: %q\ngot: %q\n", expected, changed)
+		}
+	}
+	put("<info>")
+	put()
+	e := filepath.Join(dir, "e.js")
+	if err := ioutil.WriteFile(e, []byte("var e = 10"), 0644); err != nil {
+		t.Fatalf("can't write file: %v\n", err)
+	}
+	put(e)
+	a.SetBanner("test")
+	put("<options>")
+	a.Add(filepath.Join(dir, "f.js"))
+	put("<count>")
+}
+
+func TestJoinPerFamily(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	// fake compilers mark each run
+	Commands["lessc"] = fakeTool(t, dir, "lessc", "echo '/*run*/'; cat")
+	defer delete(Commands, "lessc")
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "echo '/*run*/'; cat")
+	defer delete(Commands, "coffee")
+
+	runs := func(joinCSS, joinJS bool, ext string) int {
+		a := New()
+		a.AddString("a {}", ext)
+		a.AddString("b {}", ext)
+		a.SetCompress(false)
+		a.SetJoinCSS(joinCSS)
+		a.SetJoinJS(joinJS)
+		b, _, err := a.Build("")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		return bytes.Count(b, []byte("/*run*/"))
+	}
+	if n := runs(false, true, ".less"); n != 2 {
+		t.Fatalf("expected LESS inputs to be compiled separately\ngot: %d runs\n", n)
+	}
+	if n := runs(false, true, ".coffee"); n != 1 {
+		t.Fatalf("expected CoffeeScript inputs to be joined\ngot: %d runs\n", n)
+	}
+	if n := runs(true, false, ".less"); n != 1 {
+		t.Fatalf("expected LESS inputs to be joined\ngot: %d runs\n", n)
+	}
+	if n := runs(true, false, ".coffee"); n != 2 {
+		t.Fatalf("expected CoffeeScript inputs to be compiled separately\ngot: %d runs\n", n)
+	}
+}
+
+func TestDev(t *testing.T) {
+	dir := tempFiles(t, "c.js", "e.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "e.js"))
+	a.SetDev(true)
+	if _, err := a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if expected := files["c.js"] + "\n" + files["e.js"]; string(a.Bytes()) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(a.Bytes()))
+	}
+	devFiles := a.Files()
+	if len(devFiles) != 2 || !strings.HasPrefix(devFiles[0], "app-c-") ||
+		!strings.HasPrefix(devFiles[1], "app-e-") {
+		t.Fatalf("unexpected files of inputs: %q\n", devFiles)
+	}
+	for i, src := range []string{"c.js", "e.js"} {
+		b, err := ioutil.ReadFile(filepath.Join(out, devFiles[i]))
+		if err != nil {
+			t.Fatalf("can't read file of input: %v\n", err)
+		}
+		if string(b) != files[src] {
+			t.Fatalf("expected: %s\ngot: %s\n", files[src], string(b))
+		}
+	}
+	// unchanged asset remembers its files
+	if _, err := a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !a.cached || !reflect.DeepEqual(a.Files(), devFiles) {
+		t.Fatalf("expected: %q\ngot: %q\n", devFiles, a.Files())
+	}
+	// files of inputs are deleted with the old final file
+	a.SetDev(false)
+	a.SetCompress(false)
+	if _, err := a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.Files() != nil {
+		t.Fatalf("Files returned names out of dev mode: %q\n", a.Files())
+	}
+	for _, f := range devFiles {
+		if exists(filepath.Join(out, f)) {
+			t.Fatalf("old file %s is not deleted\n", f)
+		}
+	}
+}
+
+func TestJoinMode(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	// fake coffee wraps its input, like the real one
+	Commands["coffee"] = fakeTool(t, dir, "coffee", `echo "(function() {"; cat; echo "}).call(this);"`)
+	defer delete(Commands, "coffee")
+
+	a := New()
+	a.AddString("a = 1", ".coffee")
+	a.AddString("b = 2", ".coffee")
+	a.SetCompress(false)
+	if err := a.SetJoinMode("wrap"); err == nil {
+		t.Fatalf("SetJoinMode accepted an unknown mode")
+	}
+	if err := a.SetJoinMode(JoinIIFE); err != nil {
+		t.Fatalf("SetJoinMode returned error: %v\n", err)
+	}
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := "(function() {\na = 1}).call(this);\n\n(function() {\nb = 2}).call(this);\n"
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestSharedFile(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	put := func(a *Asset, name string) string {
+		fname, err := a.Put(out, name)
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		return fname
+	}
+	// names without asset names make the same file for the same output
+	a := New(filepath.Join(dir, "c.js"))
+	b := New(filepath.Join(dir, "c.js"))
+	for _, x := range []*Asset{a, b} {
+		x.SetCompress(false)
+		if err := x.SetNameTemplate("{{.Hash}}{{.Ext}}"); err != nil {
+			t.Fatalf("SetNameTemplate returned error: %v\n", err)
+		}
+	}
+	fname := put(a, "a")
+	if put(b, "b") != fname {
+		t.Fatalf("assets with the same output made different files")
+	}
+	// a moves on, but b still needs the file
+	a.AddString("var a;", ".js")
+	put(a, "a")
+	if !exists(filepath.Join(out, fname)) {
+		t.Fatalf("file of another asset is deleted")
+	}
+}
+
+func TestCompiledInputs(t *testing.T) {
+	dir := tempFiles(t, "a.coffee", "c.js")
+	defer os.RemoveAll(dir)
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "echo '// compiled'; cat")
+	defer delete(Commands, "coffee")
+
+	a := New(filepath.Join(dir, "a.coffee"), filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if a.CompiledInputs() != nil {
+		t.Fatalf("CompiledInputs returned inputs before build.")
+	}
+	if _, _, err := a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := []CompiledInput{
+		{filepath.Join(dir, "a.coffee"), ".coffee", []byte("// compiled\n" + files["a.coffee"])},
+		{filepath.Join(dir, "c.js"), ".js", []byte(files["c.js"])},
+	}
+	if !reflect.DeepEqual(a.CompiledInputs(), expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, a.CompiledInputs())
+	}
+}
+
+func TestVersionDir(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetFingerprint(false)
+	for _, v := range []string{"/v1", "../v1", "v1/../.."} {
+		if err := a.SetVersionDir(v); err == nil {
+			t.Fatalf("SetVersionDir accepted %q\n", v)
+		}
+	}
+	if err := a.SetVersionDir("v1"); err != nil {
+		t.Fatalf("SetVersionDir returned error: %v\n", err)
+	}
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if fname != "v1/app.js" || !exists(filepath.Join(out, "v1", "app.js")) {
+		t.Fatalf("expected: v1/app.js\ngot: %s\n", fname)
+	}
+	// a new version makes new files, and the old version is removed
+	a.SetVersionDir("v2")
+	if fname, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if fname != "v2/app.js" || !exists(filepath.Join(out, "v2", "app.js")) {
+		t.Fatalf("expected: v2/app.js\ngot: %s\n", fname)
+	}
+	if exists(filepath.Join(out, "v1")) {
+		t.Fatalf("old version directory is not deleted")
+	}
+}
+
+func TestEmitDebug(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	a.SetEmitDebug(true)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	dfname := strings.TrimSuffix(fname, ".js") + ".debug.js"
+	b, err := ioutil.ReadFile(filepath.Join(out, dfname))
+	if err != nil {
+		t.Fatalf("can't read debug file: %v\n", err)
+	}
+	if string(b) != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %s\n", files["c.js"], string(b))
+	}
+	if string(a.Bytes()) == files["c.js"] {
+		t.Fatalf("final file is not compressed")
+	}
+	// debug file is deleted with the final file
+	a.AddString("var c = 3;", ".js")
+	if _, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if exists(filepath.Join(out, dfname)) {
+		t.Fatalf("old debug file is not deleted")
+	}
+}
+
+func TestCreateDir(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetCreateDir(false)
+	if _, err := a.Put(out, ""); err == nil || exists(out) {
+		t.Fatalf("Put didn't fail for missing output directory")
+	}
+	if _, err := a.Put(filepath.Join(dir, "c.js"), ""); err == nil {
+		t.Fatalf("Put didn't fail for output directory that is a file")
+	}
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatalf("can't create output directory: %v\n", err)
+	}
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	src := filepath.Join(dir, "c.js")
+
+	a := New(src)
+	a.SetCompress(false)
+	if err := a.Verify(out, "app"); err == nil {
+		t.Fatalf("Verify didn't fail before Put")
+	}
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if err = a.Verify(out, "app"); err != nil {
+		t.Fatalf("Verify returned error: %v\n", err)
+	}
+	// new stamps keep the digest
+	mtime := time.Now().Add(time.Minute)
+	if err = os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatalf("can't change modification time: %v\n", err)
+	}
+	if _, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if err = a.Verify(out, "app"); err != nil {
+		t.Fatalf("Verify returned error: %v\n", err)
+	}
+	// verifying another directory or name leaves the asset as it is
+	outPath := a.OutputPath()
+	if err = a.Verify(filepath.Join(dir, "other"), "admin"); err == nil {
+		t.Fatalf("Verify didn't fail for another directory")
+	}
+	if a.OutputPath() != outPath {
+		t.Fatalf("expected: %s\ngot: %s\n", outPath, a.OutputPath())
+	}
+	if err = a.Verify(out, "../app"); err != ErrName {
+		t.Fatalf("expected: %v\ngot: %v\n", ErrName, err)
+	}
+	// changed file is caught
+	if err = ioutil.WriteFile(filepath.Join(out, fname), []byte("alert(1);"), 0644); err != nil {
+		t.Fatalf("can't change final file: %v\n", err)
+	}
+	if err = a.Verify(out, "app"); err == nil || !strings.Contains(err.Error(), "is changed") {
+		t.Fatalf("expected error of changed file\ngot: %v\n", err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir := tempFiles(t, "a.coffee", "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "cat")
+	defer delete(Commands, "coffee")
+
+	var steps []string
+	a := New(filepath.Join(dir, "a.coffee"), filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	a.SetProgress(func(step, detail string) {
+		steps = append(steps, strings.TrimSpace(step+" "+detail))
+	})
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	expected := []string{"expand", "read", "compile " + filepath.Join(dir, "a.coffee"), "join",
+		"compress native", "write " + fname}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, steps)
+	}
+	steps = nil
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if expected = []string{"expand", "cache-hit " + fname}; !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, steps)
+	}
+}
+
+func TestPutAll(t *testing.T) {
+	dir := tempFiles(t, "a.coffee", "c.js")
+	defer os.RemoveAll(dir)
+	dirs := []string{filepath.Join(dir, "public"), filepath.Join(dir, "cdn")}
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "cat")
+	defer delete(Commands, "coffee")
+
+	compiles := 0
+	a := New(filepath.Join(dir, "a.coffee"), filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	a.SetGzip(true)
+	a.SetProgress(func(step, detail string) {
+		if step == "compile" {
+			compiles++
+		}
+	})
+	fnames, err := a.PutAll(dirs, "app")
+	if err != nil {
+		t.Fatalf("PutAll returned error: %v\n", err)
+	}
+	if compiles != 1 {
+		t.Fatalf("expected: 1 compilation\ngot: %d\n", compiles)
+	}
+	old := fnames[dirs[1]]
+	for _, d := range dirs {
+		if fnames[d] != old {
+			t.Fatalf("expected: %s in %s\ngot: %s\n", old, d, fnames[d])
+		}
+		for _, fname := range []string{old, old + ".gz", "asset-info-app-js"} {
+			if !exists(filepath.Join(d, fname)) {
+				t.Fatalf("expected: %s in %s\ngot: no file\n", fname, d)
+			}
+		}
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dirs[0], old))
+	if c, _ := ioutil.ReadFile(filepath.Join(dirs[1], old)); !bytes.Equal(b, c) {
+		t.Fatalf("expected: %q\ngot: %q\n", b, c)
+	}
+	// each directory drops its own old files
+	err = ioutil.WriteFile(filepath.Join(dir, "c.js"), []byte("var c = 3;"), 0644)
+	if err != nil {
+		t.Fatalf("can't change test file: %v\n", err)
+	}
+	if fnames, err = a.PutAll(dirs, "app"); err != nil {
+		t.Fatalf("PutAll returned error: %v\n", err)
+	}
+	for _, d := range dirs {
+		if fnames[d] == old || !exists(filepath.Join(d, fnames[d])) {
+			t.Fatalf("expected: new final file in %s\ngot: %s\n", d, fnames[d])
+		}
+		if exists(filepath.Join(d, old)) || exists(filepath.Join(d, old+".gz")) {
+			t.Fatalf("expected: %s deleted from %s\ngot: it exists\n", old, d)
+		}
+	}
+	a.SetInfoDir(filepath.Join(dir, "info"))
+	if _, err = a.PutAll(dirs, "app"); err == nil {
+		t.Fatalf("expected: error for shared info directory\ngot: nil\n")
+	}
+}
+
+func TestLatestLink(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	latest := filepath.Join(out, "app-latest.js")
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	a.SetLatestLink(true)
+	for i, content := range []string{"var c = 3;", "var c = 4;"} {
+		if i > 0 {
+			err := ioutil.WriteFile(filepath.Join(dir, "c.js"), []byte(content), 0644)
+			if err != nil {
+				t.Fatalf("can't change test file: %v\n", err)
+			}
+		}
+		fname, err := a.Put(out, "app")
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		b, _ := ioutil.ReadFile(filepath.Join(out, fname))
+		if l, err := ioutil.ReadFile(latest); err != nil || !bytes.Equal(l, b) {
+			t.Fatalf("expected: %q\ngot: %q, %v\n", b, l, err)
+		}
+	}
+	buf, _ := ioutil.ReadFile(filepath.Join(out, "asset-info-app-js"))
+	var inf info
+	if err := json.Unmarshal(buf, &inf); err != nil || inf.Latest != "app-latest.js" {
+		t.Fatalf("expected: latest file in info\ngot: %s\n", buf)
+	}
+	a.SetLatestSymlink(true)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if target, err := os.Readlink(latest); err != nil || target != fname {
+		t.Fatalf("expected: symlink to %s\ngot: %q, %v\n", fname, target, err)
+	}
+	a.SetLatestLink(false)
+	if _, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if _, err = os.Lstat(latest); !os.IsNotExist(err) {
+		t.Fatalf("expected: %s deleted\ngot: %v\n", latest, err)
+	}
+}
+
+func TestBinaryInput(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	img := filepath.Join(dir, "logo.js")
+	err := ioutil.WriteFile(img, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0644)
+	if err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+
+	a := New(filepath.Join(dir, "*.js"))
+	a.SetCompress(false)
+	if _, err = a.Put(out, ""); err == nil || !strings.Contains(err.Error(), img) {
+		t.Fatalf("expected: error naming %s\ngot: %v\n", img, err)
+	}
+	a.SetAllowBinary(true)
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if isBinary([]byte("p::before { content: \"»\"; }\f\n")) {
+		t.Fatalf("expected: text\ngot: binary\n")
+	}
+}
+
+func TestPostBuild(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	var calls []string
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	a.SetPostBuild(func(fname, fullpath string) error {
+		if !exists(fullpath) || !exists(filepath.Join(out, "asset-info-js")) {
+			t.Fatalf("expected: files saved before the hook\ngot: no %s\n", fullpath)
+		}
+		calls = append(calls, fname)
+		return nil
+	})
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if expected := []string{fname}; !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, calls)
+	}
+	// errors of the hook are returned by Put
+	hookErr := errors.New("purge failed")
+	a.SetPostBuild(func(fname, fullpath string) error { return hookErr })
+	a.SetBanner("v2")
+	if _, err = a.Put(out, ""); !errors.Is(err, hookErr) {
+		t.Fatalf("expected: %v\ngot: %v\n", hookErr, err)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "flags.js")
+	err := ioutil.WriteFile(fname, []byte("var beta = __BETA__;\n"), 0644)
+	if err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+
+	var exts []string
+	a := New(fname)
+	a.SetCompress(false)
+	a.SetTransform(func(ext string, in []byte) ([]byte, error) {
+		exts = append(exts, ext)
+		return bytes.Replace(in, []byte("__BETA__"), []byte("true"), -1), nil
+	})
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "var beta = true;\n"; string(b) != expected {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, b)
+	}
+	if !reflect.DeepEqual(exts, []string{".js"}) {
+		t.Fatalf("expected: transform of .js\ngot: %q\n", exts)
+	}
+	// transformed content is what's hashed
+	sum, _ := hash([]byte("var beta = true;\n"), crypto.MD5)
+	if a.hashes[0] != sum {
+		t.Fatalf("expected: %s\ngot: %s\n", sum, a.hashes[0])
+	}
+	a.SetTransform(func(ext string, in []byte) ([]byte, error) {
+		return nil, errors.New("bad flag")
+	})
+	if _, _, err = a.Build(""); err == nil || !strings.Contains(err.Error(), "bad flag") {
+		t.Fatalf("expected: error of transform\ngot: %v\n", err)
+	}
+}
+
+func TestTransformChange(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+	fname := filepath.Join(dir, "flags.js")
+	err := ioutil.WriteFile(fname, []byte("var beta = __BETA__;\n"), 0644)
+	if err != nil {
+		t.Fatalf("can't create test file: %v\n", err)
+	}
+	transform := func(value string) func(string, []byte) ([]byte, error) {
+		return func(ext string, in []byte) ([]byte, error) {
+			return bytes.Replace(in, []byte("__BETA__"), []byte(value), -1), nil
+		}
+	}
+
+	a := New(fname)
+	a.SetCompress(false)
+	a.SetTransform(transform("true"))
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	// a new transform of unchanged inputs is noticed by the same asset, and by a new
+	// one that only has the info file
+	for i, value := range []string{"false", "null"} {
+		if i > 0 {
+			a = New(fname)
+			a.SetCompress(false)
+		}
+		a.SetTransform(transform(value))
+		f, err := a.Put(out, "")
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(out, f))
+		if err != nil {
+			t.Fatalf("can't read final file: %v\n", err)
+		}
+		if expected := "var beta = " + value + ";\n"; string(b) != expected {
+			t.Fatalf("expected: %q\ngot: %q\n", expected, b)
+		}
+	}
+}
+
+func TestOutputExt(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetJSCompressor("native")
+	for _, ext := range []string{"mjs", ".m/js", "."} {
+		if err := a.SetOutputExt(ext); err == nil {
+			t.Fatalf("SetOutputExt accepted %q\n", ext)
+		}
+	}
+	if err := a.SetOutputExt(".mjs"); err != nil {
+		t.Fatalf("SetOutputExt returned error: %v\n", err)
+	}
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !strings.HasSuffix(fname, ".mjs") || !exists(filepath.Join(out, "asset-info-app-mjs")) {
+		t.Fatalf("expected: .mjs final file and info file\ngot: %s\n", fname)
+	}
+	// still compressed as JS
+	b, _ := ioutil.ReadFile(filepath.Join(out, fname))
+	if expected, _ := minifyJS([]byte(files["c.js"])); !bytes.Equal(b, expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, b)
+	}
+	if err = Clean(out, "app", ".mjs"); err != nil {
+		t.Fatalf("Clean returned error: %v\n", err)
+	}
+	if exists(filepath.Join(out, fname)) {
+		t.Fatalf("Clean didn't remove %s\n", fname)
+	}
+}
+
+func TestModuleInputs(t *testing.T) {
+	dir := tempFiles(t, "a.css", "c.js", "g.mjs", "h.cjs")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"), filepath.Join(dir, "*.?js"))
+	a.SetJSCompressor("native")
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !strings.HasSuffix(fname, ".js") {
+		t.Fatalf("expected: .js final file\ngot: %s\n", fname)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(out, fname))
+	joined := files["c.js"] + "\n" + files["g.mjs"] + "\n" + files["h.cjs"]
+	if expected, _ := minifyJS([]byte(joined)); !bytes.Equal(b, expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, b)
+	}
+	a = New(filepath.Join(dir, "a.css"), filepath.Join(dir, "g.mjs"))
+	if _, err = a.Put(out, ""); err != ErrMix {
+		t.Fatalf("expected: %v\ngot: %v\n", ErrMix, err)
+	}
+}
+
+// tempFiles creates a temporary directory containing the named test files.
+func tempFiles(t testing.TB, names ...string) (dir string) {
+	dir, err := ioutil.TempDir(os.TempDir(), "asset_test")
+	if err != nil {
+		t.Fatalf("can't create temp directory: %v\n", err)
+	}
+	for _, name := range names {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(files[name]), 0644)
+		if err != nil {
+			t.Fatalf("can't create test file \"%s\": %v\n", name, err)
+		}
+	}
+	return dir
+}
+
+// fakeTool creates an executable shell script in dir that runs script, and returns
+// its path.
+func fakeTool(t testing.TB, dir, name, script string) string {
+	fname := filepath.Join(dir, name)
+	err := ioutil.WriteFile(fname, []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	if err != nil {
+		t.Fatalf("can't create fake %s: %v\n", name, err)
+	}
+	return fname
+}
@@ -0,0 +1,100 @@
+package assets
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Bundle puts several assets in the same directory, and records all of them in the
+// manifest file of that directory at once. It's made by NewBundle:
+//
+//         b := assets.NewBundle("static")
+//         b.Add("app", assets.New("assets/scripts/*.coffee"))
+//         b.Add("style", assets.New("assets/style/*.less"))
+//         names, err := b.Build()
+//
+// Assets of a bundle don't need manifest enabled; the bundle writes the manifest
+// itself, keyed like the manifest of single assets. See ManifestFname.
+type Bundle struct {
+	dir      string
+	names    []string          // names of assets, in the order they're added
+	assets   map[string]*Asset // assets keyed by their names
+	parallel bool              // should put assets at the same time?
+}
+
+// NewBundle makes an empty Bundle that puts its assets in dir.
+func NewBundle(dir string) *Bundle {
+	return &Bundle{dir: dir, assets: make(map[string]*Asset)}
+}
+
+// Add adds asset a to the bundle with the given name, which is passed to Put of a.
+// An asset that's added before with the same name is replaced.
+func (b *Bundle) Add(name string, a *Asset) {
+	if _, ok := b.assets[name]; !ok {
+		b.names = append(b.names, name)
+	}
+	b.assets[name] = a
+}
+
+// SetParallel makes Build put the assets at the same time, instead of one after the
+// other. Since each asset runs its own external tools, this can make building of
+// bundles with many assets faster.
+func (b *Bundle) SetParallel(parallel bool) {
+	b.parallel = parallel
+}
+
+// Build puts all the assets of the bundle in its directory, and writes the manifest
+// file once, after all of them are put. It returns names of the final files, keyed by
+// names of the assets. If any of the assets fails, the manifest is not written.
+func (b *Bundle) Build() (map[string]string, error) {
+	fnames := make([]string, len(b.names))
+	errs := make([]error, len(b.names))
+	put := func(i int) {
+		name := b.names[i]
+		fnames[i], errs[i] = b.assets[name].Put(b.dir, name)
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("assets: can't put %q: %w", name, errs[i])
+		}
+	}
+	if b.parallel {
+		var wg sync.WaitGroup
+		for i := range b.names {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				put(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range b.names {
+			if put(i); errs[i] != nil {
+				break
+			}
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	m := make(map[string]string)
+	entries := make(map[string]string)
+	mode := os.FileMode(0666)
+	for i, name := range b.names {
+		a := b.assets[name]
+		m[name] = fnames[i]
+		entries[name+a.fileExt()] = fnames[i]
+		if i == 0 {
+			// manifest gets permissions of the files of the first asset
+			mode = a.fileMode
+		}
+	}
+	unlock := lockDir(b.dir)
+	defer unlock()
+	if err := updateManifest(b.dir, entries, mode); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
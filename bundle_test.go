@@ -0,0 +1,54 @@
+package assets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	dir := tempFiles(t, "a.css", "c.js")
+	defer os.RemoveAll(dir)
+
+	for _, parallel := range []bool{false, true} {
+		out := filepath.Join(dir, outDir)
+		b := NewBundle(out)
+		b.SetParallel(parallel)
+		for name, src := range map[string]string{"app": "c.js", "style": "a.css"} {
+			a := New(filepath.Join(dir, src))
+			a.SetCompress(false)
+			b.Add(name, a)
+		}
+		names, err := b.Build()
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		for _, fname := range names {
+			if !exists(filepath.Join(out, fname)) {
+				t.Fatalf("Build didn't write \"%s\".", fname)
+			}
+		}
+		buf, err := ioutil.ReadFile(filepath.Join(out, ManifestFname))
+		if err != nil {
+			t.Fatalf("can't read manifest: %v\n", err)
+		}
+		var m map[string]string
+		if err = json.Unmarshal(buf, &m); err != nil {
+			t.Fatalf("can't parse manifest: %v\n", err)
+		}
+		expected := map[string]string{"app.js": names["app"], "style.css": names["style"]}
+		if !reflect.DeepEqual(m, expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, m)
+		}
+	}
+
+	// failure of an asset is reported
+	b := NewBundle(filepath.Join(dir, outDir))
+	b.Add("empty", New())
+	if _, err := b.Build(); err == nil {
+		t.Fatalf("Build didn't return error of empty asset.")
+	}
+}
@@ -0,0 +1,88 @@
+package assets
+
+import (
+	"bytes"
+	"crypto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// runCompiler runs compiler cmd with args like runCmd, but if a has a compile cache,
+// the output is looked up there first, and saved there after running cmd, unless it's
+// a dry run. Entries are keyed by the tool, its arguments, and the input. The tool is identified by its path
+// and the modification time and size of its file, so that upgrading it invalidates
+// the entries.
+func (a *Asset) runCompiler(in []byte, cmd string, args ...string) (out []byte, err error) {
+	if len(a.compileCache) == 0 {
+		return a.runCmd(in, cmd, args...)
+	}
+	key, err := a.cacheKey(in, cmd, args)
+	if err != nil {
+		// runCmd explains tools that are not found
+		return a.runCmd(in, cmd, args...)
+	}
+	fname := filepath.Join(a.compileCache, key)
+	if out, err = ioutil.ReadFile(fname); err == nil {
+		a.logf("used cached output of %s", cmd)
+		return out, nil
+	}
+	if out, err = a.runCmd(in, cmd, args...); err != nil || a.dryRun {
+		return out, err
+	}
+	if err = a.saveCached(fname, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// cacheKey returns the key of the compile cache entry of running cmd with args on in,
+// in the tool environment of a.
+func (a *Asset) cacheKey(in []byte, cmd string, args []string) (string, error) {
+	if c, ok := Commands[cmd]; ok {
+		cmd = c
+	}
+	p, err := a.lookPath(cmd)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	b.WriteString(p + "\x00" + fi.ModTime().String() + "\x00" +
+		strconv.FormatInt(fi.Size(), 10) + "\x00")
+	for _, arg := range args {
+		b.WriteString(arg + "\x00")
+	}
+	for _, v := range a.toolEnv {
+		b.WriteString(v + "\x00")
+	}
+	b.Write(in)
+	return hash(b.Bytes(), crypto.SHA256)
+}
+
+// saveCached saves compiler output b in the compile cache as file fname. It's written
+// to a temporary file first, so that other builds never read a partial entry.
+func (a *Asset) saveCached(fname string, b []byte) error {
+	if err := os.MkdirAll(a.compileCache, a.dirMode); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(a.compileCache, "tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), fname)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package assets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileCache(t *testing.T) {
+	dir := tempFiles(t, "a.coffee")
+	defer os.RemoveAll(dir)
+	runs := filepath.Join(dir, "runs")
+	Commands["coffee"] = fakeTool(t, dir, "coffee", "echo run >> "+runs+"; cat")
+	defer delete(Commands, "coffee")
+
+	build := func(extra string) {
+		a := New(filepath.Join(dir, "a.coffee"))
+		if len(extra) > 0 {
+			a.AddString(extra, ".js")
+		}
+		a.SetCompress(false)
+		a.SetCompileCache(filepath.Join(dir, "cache"))
+		b, _, err := a.Build("")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		if !strings.HasPrefix(string(b), files["a.coffee"]) {
+			t.Fatalf("expected: %s\ngot: %s\n", files["a.coffee"], string(b))
+		}
+	}
+	count := func() int {
+		b, _ := ioutil.ReadFile(runs)
+		return strings.Count(string(b), "run")
+	}
+	build("")
+	build("")
+	// another asset with the same source
+	build("window.x = 1;")
+	if n := count(); n != 1 {
+		t.Fatalf("expected coffee to run once\ngot: %d runs\n", n)
+	}
+	// a new version of the tool
+	fakeTool(t, dir, "coffee", "echo run >> "+runs+"; cat -")
+	build("")
+	if n := count(); n != 2 {
+		t.Fatalf("expected coffee to run again after upgrade\ngot: %d runs\n", n)
+	}
+}
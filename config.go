@@ -0,0 +1,107 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Config declares an asset build as data, rather than calls to setters of Asset, so
+// that it can be decoded from JSON, or from YAML by packages that use JSON tags. It's
+// used by BuildFromConfig. Fields that are left zero keep the defaults of New; the
+// ones that default to true are pointers, so that they can be set to false.
+type Config struct {
+	Inputs        []string          `json:"inputs"`                  // file names and globs, see Add
+	Ignore        []string          `json:"ignore,omitempty"`        // patterns of ignored files, see Ignore
+	Dir           string            `json:"dir"`                     // output directory
+	Name          string            `json:"name,omitempty"`          // name of the asset
+	Compress      *bool             `json:"compress,omitempty"`      // see SetCompress
+	Join          *bool             `json:"join,omitempty"`          // see SetJoin
+	Fingerprint   *bool             `json:"fingerprint,omitempty"`   // see SetFingerprint
+	Hash          string            `json:"hash,omitempty"`          // see SetHash
+	HashLength    int               `json:"hashLength,omitempty"`    // see SetHashLength
+	CSSCompressor string            `json:"cssCompressor,omitempty"` // see SetCSSCompressor
+	JSCompressor  string            `json:"jsCompressor,omitempty"`  // see SetJSCompressor
+	SourceMaps    bool              `json:"sourceMaps,omitempty"`    // see SetSourceMaps
+	Dev           bool              `json:"dev,omitempty"`           // see SetDev
+	Banner        string            `json:"banner,omitempty"`        // see SetBanner
+	Variables     map[string]string `json:"variables,omitempty"`     // see SetVariables
+	Gzip          bool              `json:"gzip,omitempty"`          // see SetGzip
+	Brotli        bool              `json:"brotli,omitempty"`        // see SetBrotli
+	Manifest      bool              `json:"manifest,omitempty"`      // see SetManifest
+	InfoDir       string            `json:"infoDir,omitempty"`       // see SetInfoDir
+}
+
+// BuildFromConfig makes an Asset as cfg declares, and puts it in the directory of cfg.
+// It returns name of the final file, like Put. cfg is checked before anything is
+// built, and errors describe what's wrong with it, like missing fields, unknown
+// values, or fields that can't be used together.
+func BuildFromConfig(cfg Config) (string, error) {
+	a, err := cfg.asset()
+	if err != nil {
+		return "", err
+	}
+	return a.Put(cfg.Dir, cfg.Name)
+}
+
+// asset checks cfg and makes the Asset that it declares.
+func (cfg Config) asset() (*Asset, error) {
+	if len(cfg.Inputs) == 0 {
+		return nil, errors.New("assets: config has no inputs")
+	}
+	if len(cfg.Dir) == 0 {
+		return nil, errors.New("assets: config has no output directory")
+	}
+	if err := checkName(cfg.Name); err != nil {
+		return nil, fmt.Errorf("assets: config has bad name %q: %w", cfg.Name, err)
+	}
+	compress := cfg.Compress == nil || *cfg.Compress
+	// dev mode doesn't compress
+	if cfg.SourceMaps && compress && !cfg.Dev {
+		return nil, errors.New("assets: config asks for source maps of compressed " +
+			"output; set compress to false")
+	}
+	if cfg.Dev && cfg.Compress != nil && *cfg.Compress {
+		return nil, errors.New("assets: config asks for compression in dev mode, " +
+			"which doesn't compress")
+	}
+	if cfg.Dev && cfg.Join != nil && *cfg.Join {
+		return nil, errors.New("assets: config asks for joining in dev mode, " +
+			"which doesn't join")
+	}
+	a := New(cfg.Inputs...)
+	a.Ignore(cfg.Ignore...)
+	if len(cfg.Hash) > 0 {
+		if err := a.SetHash(cfg.Hash); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.SetHashLength(cfg.HashLength); err != nil {
+		return nil, err
+	}
+	if len(cfg.CSSCompressor) > 0 {
+		a.SetCSSCompressor(cfg.CSSCompressor)
+	}
+	if len(cfg.JSCompressor) > 0 {
+		a.SetJSCompressor(cfg.JSCompressor)
+	}
+	a.SetCompress(compress)
+	if cfg.Join != nil {
+		a.SetJoin(*cfg.Join)
+	}
+	if cfg.Fingerprint != nil {
+		a.SetFingerprint(*cfg.Fingerprint)
+	}
+	a.SetSourceMaps(cfg.SourceMaps)
+	a.SetDev(cfg.Dev)
+	a.SetBanner(cfg.Banner)
+	a.SetVariables(cfg.Variables)
+	a.SetGzip(cfg.Gzip)
+	a.SetBrotli(cfg.Brotli)
+	a.SetManifest(cfg.Manifest)
+	a.SetInfoDir(cfg.InfoDir)
+	// inputs that can't make one asset are caught before building
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
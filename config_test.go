@@ -0,0 +1,46 @@
+package assets
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildFromConfig(t *testing.T) {
+	dir := tempFiles(t, "a.css", "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	var cfg Config
+	src := `{"inputs": ["` + filepath.Join(dir, "*.js") + `"], "dir": "` + out + `",
+		"name": "app", "compress": false, "hash": "sha1", "hashLength": 8}`
+	if err := json.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatalf("can't decode config: %v\n", err)
+	}
+	fname, err := BuildFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildFromConfig returned error: %v\n", err)
+	}
+	if len(fname) != len("app-12345678.js") || !exists(filepath.Join(out, fname)) {
+		t.Fatalf("expected: app-<8 hex>.js in %s\ngot: %s\n", out, fname)
+	}
+
+	no, yes := false, true
+	bad := map[string]Config{
+		"no inputs":          {Dir: out},
+		"no output":          {Inputs: cfg.Inputs},
+		"bad name":           {Inputs: cfg.Inputs, Dir: out, Name: "../app"},
+		"unsupported hash":   {Inputs: cfg.Inputs, Dir: out, Hash: "crc32"},
+		"short hash":         {Inputs: cfg.Inputs, Dir: out, Compress: &no, HashLength: 3},
+		"compressed maps":    {Inputs: cfg.Inputs, Dir: out, SourceMaps: true},
+		"mixed inputs":       {Inputs: []string{filepath.Join(dir, "*")}, Dir: out, Compress: &no},
+		"compression in dev": {Inputs: cfg.Inputs, Dir: out, Dev: true, Compress: &yes},
+	}
+	for name, cfg := range bad {
+		if _, err := BuildFromConfig(cfg); err == nil || !strings.HasPrefix(err.Error(), "assets: ") {
+			t.Fatalf("expected: error for %s\ngot: %v\n", name, err)
+		}
+	}
+}
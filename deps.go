@@ -0,0 +1,90 @@
+package assets
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// lessImportRe matches @import rules of LESS, with or without options like
+// "(reference)".
+var lessImportRe = regexp.MustCompile(`@import\s*(?:\([^)]*\)\s*)?(?:url\(\s*)?["']([^"']+)["']`)
+
+// lessDeps returns contents of the files that are imported by LESS file fname with
+// content b, recursively, and records their stamps in a. Imports are resolved
+// relative to the importing file, and the ones that can't be found there, like the
+// ones in include paths of lessc, are ignored. seen has names of the files that are
+// already visited, which guards against circular imports.
+func (a *Asset) lessDeps(fname string, b []byte, seen map[string]bool) ([]byte, error) {
+	var deps []byte
+	for _, m := range lessImportRe.FindAllSubmatch(b, -1) {
+		addr := string(m[1])
+		if strings.Contains(addr, "//") {
+			// URLs can't be changed by editing files
+			continue
+		}
+		if len(path.Ext(addr)) == 0 {
+			// LESS adds the extension of imports that don't have one
+			addr += ".less"
+		}
+		imported := path.Join(path.Dir(fname), addr)
+		if seen[imported] {
+			continue
+		}
+		seen[imported] = true
+		// stamp is made before reading, like stamps of inputs
+		fi, err := a.statFile(imported)
+		if err != nil {
+			continue
+		}
+		content, err := a.readFile(imported)
+		if err != nil {
+			return nil, err
+		}
+		a.depStamps = append(a.depStamps, stamp{Fname: imported, ModTime: fi.ModTime(),
+			Size: fi.Size()})
+		deps = append(deps, content...)
+		sub, err := a.lessDeps(imported, content, seen)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, sub...)
+	}
+	return deps, nil
+}
+
+// inputLessDeps returns what lessDeps returns for LESS input inp. Imports of joined
+// inputs are found in each of their parts, relative to the file of that part.
+func (a *Asset) inputLessDeps(inp input, seen map[string]bool) ([]byte, error) {
+	if len(inp.parts) == 0 {
+		if len(inp.fname) == 0 || isURL(inp.fname) {
+			return nil, nil
+		}
+		return a.lessDeps(inp.fname, inp.bytes, seen)
+	}
+	var deps []byte
+	for _, part := range inp.parts {
+		b, err := a.inputLessDeps(part, seen)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, b...)
+	}
+	return deps, nil
+}
+
+// sameDepStamps reports whether the files with stamps l are not changed since the
+// stamps were made.
+func (a *Asset) sameDepStamps(l []stamp) bool {
+	for _, s := range l {
+		fi, err := a.statFile(s.Fname)
+		if err != nil {
+			return false
+		}
+		now := stamp{Fname: s.Fname, ModTime: fi.ModTime(), Size: fi.Size()}
+		if !sameInputStamps([]stamp{s}, []stamp{now}) {
+			return false
+		}
+	}
+	return true
+}
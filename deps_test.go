@@ -0,0 +1,107 @@
+package assets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLessDeps(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["lessc"] = fakeTool(t, dir, "lessc", "cat")
+	defer delete(Commands, "lessc")
+
+	srcs := map[string]string{
+		"main.less":       "@import \"lib/vars\";\nbody { color: @color; }",
+		"lib/vars.less":   "@import (reference) 'colors.less';\n@color: @red;",
+		"lib/colors.less": "@import \"vars\";\n@red: #f00;",
+	}
+	for name, content := range srcs {
+		fname := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("can't create test file \"%s\": %v\n", name, err)
+		}
+	}
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "main.less"))
+	a.SetCompress(false)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if len(a.depStamps) != 2 {
+		t.Fatalf("expected 2 imported files\ngot: %v\n", a.depStamps)
+	}
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !a.cached {
+		t.Fatalf("Put didn't notice that nothing is changed.")
+	}
+
+	// editing an imported file is a change
+	colors := filepath.Join(dir, "lib/colors.less")
+	if err := ioutil.WriteFile(colors, []byte("@red: #e00;"), 0644); err != nil {
+		t.Fatalf("can't change imported file: %v\n", err)
+	}
+	mtime := time.Now().Add(time.Second)
+	os.Chtimes(colors, mtime, mtime)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached {
+		t.Fatalf("Put didn't notice the change of imported file.")
+	}
+}
+
+func TestJoinedLessDeps(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["lessc"] = fakeTool(t, dir, "lessc", "cat")
+	defer delete(Commands, "lessc")
+
+	// each input imports a file with the same name next to it
+	srcs := map[string]string{
+		"a/main.less":  "@import \"vars\";\na { color: @a; }",
+		"a/vars.less":  "@a: #f00;",
+		"b/admin.less": "@import \"vars\";\nb { color: @b; }",
+		"b/vars.less":  "@b: #00f;",
+	}
+	for name, content := range srcs {
+		fname := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("can't create test file \"%s\": %v\n", name, err)
+		}
+	}
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "a/main.less"), filepath.Join(dir, "b/admin.less"))
+	a.SetCompress(false)
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	var deps []string
+	for _, s := range a.depStamps {
+		deps = append(deps, s.Fname)
+	}
+	expected := []string{filepath.Join(dir, "a/vars.less"), filepath.Join(dir, "b/vars.less")}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, deps)
+	}
+
+	// editing import of the second input is a change
+	vars := filepath.Join(dir, "b/vars.less")
+	if err := ioutil.WriteFile(vars, []byte("@b: #00e;"), 0644); err != nil {
+		t.Fatalf("can't change imported file: %v\n", err)
+	}
+	if _, err := a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached {
+		t.Fatalf("Put didn't notice the change of file imported by second input.")
+	}
+}
@@ -0,0 +1,140 @@
+package assets
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// glob returns names of input files matching pattern. Besides the syntax of
+// filepath.Match, pattern can have "**" as a whole path element, which matches any
+// number of directories, including none. So "js/**/*.js" matches "js/a.js" and
+// "js/lib/b/c.js".
+func (a *Asset) glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		if a.fsys != nil {
+			return fs.Glob(a.fsys, pattern)
+		}
+		return filepath.Glob(pattern)
+	}
+	elems := strings.Split(path.Clean(filepath.ToSlash(pattern)), "/")
+	// walk from the deepest directory that has no meta characters
+	n := 0
+	for n < len(elems)-1 && !strings.ContainsAny(elems[n], `*?[\`) {
+		n++
+	}
+	root := strings.Join(elems[:n], "/")
+	if n == 1 && len(elems[0]) == 0 {
+		root = "/"
+	} else if n == 0 {
+		root = "."
+	}
+	var matches []string
+	walk := func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == root && os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name = filepath.ToSlash(name)
+		if ok, err := matchElems(elems, strings.Split(name, "/")); err != nil {
+			return err
+		} else if ok {
+			matches = append(matches, filepath.FromSlash(name))
+		}
+		return nil
+	}
+	var err error
+	if a.fsys != nil {
+		err = fs.WalkDir(a.fsys, root, walk)
+	} else {
+		err = filepath.WalkDir(filepath.FromSlash(root), walk)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// matchElems reports whether path elements of name match path elements of pattern,
+// which may include "**" elements.
+func matchElems(pattern, name []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(name) == 0, nil
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if ok, err := matchElems(pattern[1:], name[i:]); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(name) == 0 {
+		return false, nil
+	}
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false, err
+	}
+	return matchElems(pattern[1:], name[1:])
+}
+
+// ignorePatterns returns the ignore patterns of a, resolved against its base
+// directory, followed by the ones in its ignore file. Lines of the ignore file that
+// are empty or start with "#" are skipped, and patterns with a slash are made
+// relative to the directory of the ignore file.
+func (a *Asset) ignorePatterns() ([]string, error) {
+	var patterns []string
+	for _, pattern := range a.ignores {
+		if strings.Contains(filepath.ToSlash(pattern), "/") {
+			pattern = a.resolve(pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if len(a.ignoreFile) == 0 {
+		return patterns, nil
+	}
+	b, err := a.readFile(a.ignoreFile)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(filepath.ToSlash(a.ignoreFile))
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "/") {
+			line = path.Join(dir, line)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// ignored reports whether input file name matches any of patterns. Patterns without
+// a slash are matched against the base name of the file, and the others against the
+// whole name, like the globs.
+func ignored(patterns []string, name string) (bool, error) {
+	name = path.Clean(filepath.ToSlash(name))
+	for _, pattern := range patterns {
+		var ok bool
+		var err error
+		if strings.Contains(pattern, "/") {
+			elems := strings.Split(path.Clean(filepath.ToSlash(pattern)), "/")
+			ok, err = matchElems(elems, strings.Split(name, "/"))
+		} else {
+			ok, err = path.Match(pattern, path.Base(name))
+		}
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
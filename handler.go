@@ -0,0 +1,130 @@
+package assets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// served is the final file that the handler of an Asset serves, made by the last
+// successful call to Put or Build. It's never changed once it's made; each Put or
+// Build replaces it as a whole, so that handlers can read it while the asset is being
+// built again, like by Watch.
+type served struct {
+	dir, fname  string   // output directory, and name of final file
+	ext         string   // extension, either ".css" or ".js"
+	bytes       []byte   // content of final file, or nil if it's only on disk
+	sidecars    []string // names of files next to final file
+	fingerprint bool     // does name of final file change with its content?
+}
+
+// publish makes the final file of the last build of a the one that its handlers
+// serve.
+func (a *Asset) publish() {
+	s := &served{a.dir, a.fname, a.ext, a.bytes, a.sidecars, a.fingerprint}
+	if a.cached {
+		s.bytes = nil
+	}
+	a.served.Store(s)
+}
+
+// encodings keeps the content encodings of precompressed sidecars, by extension of
+// the sidecars, in order of preference.
+var encodings = []struct{ ext, name string }{
+	{".br", "br"},
+	{".gz", "gzip"},
+}
+
+// Handler returns an HTTP handler that serves the final file made by the last call to
+// Put or Build at urlPath, like "/static", followed by name of the file. Requests for
+// any other file, including old final files, get 404. Since the name of the file
+// changes with its content, responses are cached for a year, unless fingerprint is
+// disabled. If the client accepts them, Brotli or gzip sidecars written by Put are
+// served instead of the file.
+//
+// The handler always serves the current final file, so it can be used with Watch. It
+// can run while the asset is being built again, and keeps serving the previous file
+// until the build is done.
+func (a *Asset) Handler(urlPath string) http.Handler {
+	prefix := strings.TrimSuffix(urlPath, "/") + "/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := a.served.Load().(*served)
+		if s == nil || r.URL.Path != prefix+s.fname {
+			http.NotFound(w, r)
+			return
+		}
+		b := s.bytes
+		if b == nil {
+			var err error
+			if b, err = ioutil.ReadFile(path.Join(s.dir, s.fname)); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		h := w.Header()
+		if s.ext == ".css" {
+			h.Set("Content-Type", "text/css; charset=utf-8")
+		} else {
+			h.Set("Content-Type", "text/javascript; charset=utf-8")
+		}
+		if s.fingerprint {
+			h.Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			h.Set("Cache-Control", "no-cache")
+		}
+		h.Add("Vary", "Accept-Encoding")
+		if enc, eb := s.encoded(r); eb != nil {
+			h.Set("Content-Encoding", enc)
+			b = eb
+		}
+		http.ServeContent(w, r, s.fname, time.Time{}, bytes.NewReader(b))
+	})
+}
+
+// encoded returns name and content of the precompressed sidecar of the final file
+// that's accepted by the client of request r, or nil if there's none.
+func (s *served) encoded(r *http.Request) (string, []byte) {
+	if len(s.dir) == 0 {
+		// built in memory, so there are no sidecars
+		return "", nil
+	}
+	for _, enc := range encodings {
+		if !accepts(r.Header.Get("Accept-Encoding"), enc.name) {
+			continue
+		}
+		for _, fname := range s.sidecars {
+			if fname != s.fname+enc.ext {
+				continue
+			}
+			b, err := ioutil.ReadFile(path.Join(s.dir, fname))
+			if err == nil {
+				return enc.name, b
+			}
+		}
+	}
+	return "", nil
+}
+
+// accepts reports whether Accept-Encoding header value header allows encoding enc.
+func accepts(header, enc string) bool {
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		if strings.TrimSpace(params[0]) != enc {
+			continue
+		}
+		// q=0 means not acceptable
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
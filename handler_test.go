@@ -0,0 +1,115 @@
+package assets
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetGzip(true)
+	fname, err := a.Put(filepath.Join(dir, outDir), "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	h := a.Handler("/static/")
+
+	get := func(url, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", url, nil)
+		if len(acceptEncoding) > 0 {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	w := get("/static/"+fname, "")
+	if w.Code != 200 || w.Body.String() != files["c.js"] {
+		t.Fatalf("expected: %s\ngot: %d %s\n", files["c.js"], w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+		t.Fatalf("expected: text/javascript; charset=utf-8\ngot: %s\n", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Fatalf("unexpected Cache-Control: %s\n", cc)
+	}
+
+	// gzip sidecar is served to clients that accept it
+	w = get("/static/"+fname, "br;q=0, gzip")
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected: gzip\ngot: %s\n", enc)
+	}
+	if b, _ := gzipBytes([]byte(files["c.js"])); w.Body.String() != string(b) {
+		t.Fatalf("handler didn't serve gzip sidecar")
+	}
+	w = get("/static/"+fname, "gzip;q=0")
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected no encoding\ngot: %s\n", enc)
+	}
+
+	for _, url := range []string{"/static/other.js", "/" + fname, "/static/" + fname + ".gz"} {
+		if w = get(url, ""); w.Code != 404 {
+			t.Fatalf("expected 404 for %s\ngot: %d\n", url, w.Code)
+		}
+	}
+}
+
+func TestHandlerWhileBuilding(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	a.SetGzip(true)
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	h := a.Handler("/static")
+
+	// the asset is built again while it's served, like by Watch
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 20; i++ {
+			src := "var c = " + strings.Repeat("1", i+1) + ";"
+			if err := ioutil.WriteFile(filepath.Join(dir, "c.js"), []byte(src), 0644); err != nil {
+				done <- err
+				return
+			}
+			if _, err := a.Put(out, "app"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for building := true; building; {
+		select {
+		case err = <-done:
+			if err != nil {
+				t.Fatalf("Put returned error: %v\n", err)
+			}
+			building = false
+		default:
+		}
+		r := httptest.NewRequest("GET", "/static/"+fname, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	// the handler serves the last file
+	fname = a.fname
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/static/"+fname, nil))
+	if expected := "var c = " + strings.Repeat("1", 20) + ";"; w.Body.String() != expected {
+		t.Fatalf("expected: %s\ngot: %d %s\n", expected, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,224 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// urlRe matches url() references in CSS. The address is in one of the three groups,
+// depending on how it's quoted.
+var urlRe = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+
+// fontTypes keeps media types of font files, which are often missing from the system
+// tables of mime package.
+var fontTypes = map[string]string{
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".eot":   "application/vnd.ms-fontobject",
+}
+
+// inlineURLs replaces url() references of CSS inputs to small files with data URIs.
+// Addresses are resolved relative to the directory of the input file, so inputs
+// added from memory or URLs are left as they are.
+func (a *Asset) inlineURLs() error {
+	for i, inp := range a.inputs {
+		if len(inp.fname) == 0 || isURL(inp.fname) {
+			continue
+		}
+		dir := path.Dir(inp.fname)
+		var err error
+		a.inputs[i].bytes = urlRe.ReplaceAllFunc(inp.bytes, func(m []byte) []byte {
+			if err != nil {
+				return m
+			}
+			sub := urlRe.FindSubmatch(m)
+			addr := string(sub[1]) + string(sub[2]) + string(sub[3])
+			var uri string
+			if uri, err = a.dataURI(dir, addr); err != nil || len(uri) == 0 {
+				return m
+			}
+			return []byte(`url("` + uri + `")`)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataURI returns data URI of the file at addr, relative to dir, or an empty string if
+// the file shouldn't be inlined.
+func (a *Asset) dataURI(dir, addr string) (string, error) {
+	fname, typ := a.inlined(dir, addr)
+	if len(fname) == 0 {
+		return "", nil
+	}
+	b, err := a.readFile(fname)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// inlined returns name and media type of the file at addr, relative to dir, or empty
+// strings if the file shouldn't be inlined: if it's not a local file, doesn't exist,
+// is larger than the limit of a, or has an unknown type.
+func (a *Asset) inlined(dir, addr string) (fname, typ string) {
+	if len(addr) == 0 || strings.HasPrefix(addr, "data:") || strings.HasPrefix(addr, "/") ||
+		strings.HasPrefix(addr, "#") || strings.Contains(addr, "://") {
+		return "", ""
+	}
+	// query and fragment are only meaningful to the server
+	if i := strings.IndexAny(addr, "?#"); i >= 0 {
+		addr = addr[:i]
+	}
+	ext := strings.ToLower(path.Ext(addr))
+	typ, ok := fontTypes[ext]
+	if !ok {
+		typ = mime.TypeByExtension(ext)
+	}
+	if len(typ) == 0 {
+		return "", ""
+	}
+	fname = path.Join(dir, addr)
+	fi, err := a.statFile(fname)
+	if err != nil || fi.IsDir() || fi.Size() > int64(a.inlineMax) {
+		return "", ""
+	}
+	return fname, typ
+}
+
+// inlinedDeps returns content of the files that url() references of CSS input inp
+// would inline, and adds their stamps to the dependencies of a, like lessDeps does,
+// so that editing them is noticed as a change of the input. Parts of joined inputs
+// are resolved relative to their own files. seen has names of the files that are
+// already added.
+func (a *Asset) inlinedDeps(inp input, seen map[string]bool) ([]byte, error) {
+	if len(inp.parts) > 0 {
+		var deps []byte
+		for _, part := range inp.parts {
+			b, err := a.inlinedDeps(part, seen)
+			if err != nil {
+				return nil, err
+			}
+			deps = append(deps, b...)
+		}
+		return deps, nil
+	}
+	if len(inp.fname) == 0 || isURL(inp.fname) {
+		return nil, nil
+	}
+	var deps []byte
+	for _, sub := range urlRe.FindAllSubmatch(inp.bytes, -1) {
+		addr := string(sub[1]) + string(sub[2]) + string(sub[3])
+		fname, _ := a.inlined(path.Dir(inp.fname), addr)
+		if len(fname) == 0 || seen[fname] {
+			continue
+		}
+		seen[fname] = true
+		// stamp is made before reading, like stamps of inputs
+		fi, err := a.statFile(fname)
+		if err != nil {
+			continue
+		}
+		b, err := a.readFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		a.depStamps = append(a.depStamps, stamp{Fname: fname, ModTime: fi.ModTime(),
+			Size: fi.Size()})
+		deps = append(deps, b...)
+	}
+	return deps, nil
+}
+
+// rebaseURLs rewrites relative url() references of CSS inputs, which are relative to
+// directory from of the URL base of a, to be relative to directory to of it, so that
+// they still work when the CSS is moved to the output directory. Absolute addresses
+// and data URIs are left as they are.
+func (a *Asset) rebaseURLs() {
+	for i, inp := range a.inputs {
+		a.inputs[i].bytes = urlRe.ReplaceAllFunc(inp.bytes, func(m []byte) []byte {
+			sub := urlRe.FindSubmatch(m)
+			addr := string(sub[1]) + string(sub[2]) + string(sub[3])
+			if len(addr) == 0 || strings.HasPrefix(addr, "data:") || strings.HasPrefix(addr, "/") ||
+				strings.HasPrefix(addr, "#") || strings.Contains(addr, "://") {
+				return m
+			}
+			// query and fragment are kept as they are
+			suffix := ""
+			if i := strings.IndexAny(addr, "?#"); i >= 0 {
+				addr, suffix = addr[:i], addr[i:]
+			}
+			target := filepath.FromSlash(path.Join(a.urlBase[0], addr))
+			rel, err := filepath.Rel(filepath.FromSlash(a.urlBase[1]), target)
+			if err != nil {
+				return m
+			}
+			return []byte(`url("` + filepath.ToSlash(rel) + suffix + `")`)
+		})
+	}
+}
+
+// importRe matches @import rules of CSS without media queries. The address is in one
+// of the groups, depending on how it's written.
+var importRe = regexp.MustCompile(`@import\s+(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)|"([^"]*)"|'([^']*)')\s*;`)
+
+// inlineImports replaces @import rules of plain CSS input files with content of the
+// files they import, recursively. Addresses are resolved relative to the importing
+// file. URLs, absolute addresses, and rules with media queries are left as they are.
+func (a *Asset) inlineImports() error {
+	for i, inp := range a.inputs {
+		if inp.ext != ".css" || len(inp.fname) == 0 || isURL(inp.fname) {
+			continue
+		}
+		b, err := a.spliceImports(inp.fname, inp.bytes, []string{path.Clean(inp.fname)})
+		if err != nil {
+			return err
+		}
+		a.inputs[i].bytes = b
+	}
+	return nil
+}
+
+// spliceImports inlines imports of file fname with content b. stack has names of the
+// files that are being inlined, to detect circular imports.
+func (a *Asset) spliceImports(fname string, b []byte, stack []string) ([]byte, error) {
+	var err error
+	b = importRe.ReplaceAllFunc(b, func(m []byte) []byte {
+		if err != nil {
+			return m
+		}
+		sub := importRe.FindSubmatch(m)
+		addr := string(bytes.Join(sub[1:], nil))
+		if len(addr) == 0 || strings.HasPrefix(addr, "/") || strings.Contains(addr, "//") {
+			return m
+		}
+		imported := path.Join(path.Dir(fname), addr)
+		for _, name := range stack {
+			if name == imported {
+				err = fmt.Errorf("assets: circular @import of %s in %s", imported, fname)
+				return m
+			}
+		}
+		var content []byte
+		if content, err = a.readFile(imported); err != nil {
+			return m
+		}
+		content = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+		content, err = a.spliceImports(imported, content, append(stack, imported))
+		return content
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
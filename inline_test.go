@@ -0,0 +1,128 @@
+package assets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInlineAssets(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+
+	css := `a { background: url(img/dot.png?v=1); }
+b { background: url("big.png"); }
+i { background: url('missing.png'); }
+u { background: url(https://example.com/dot.png); }
+`
+	srcs := map[string][]byte{
+		"style.css":   []byte(css),
+		"img/dot.png": []byte("dot"),
+		"big.png":     bytes.Repeat([]byte("x"), 100),
+	}
+	for name, b := range srcs {
+		fname := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := ioutil.WriteFile(fname, b, 0644); err != nil {
+			t.Fatalf("can't create test file \"%s\": %v\n", name, err)
+		}
+	}
+	a := New(filepath.Join(dir, "style.css"))
+	a.SetCompress(false)
+	a.SetInlineAssets(10)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := `a { background: url("data:image/png;base64,ZG90"); }
+b { background: url("big.png"); }
+i { background: url('missing.png'); }
+u { background: url(https://example.com/dot.png); }
+`
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+
+	// editing an inlined file is a change, for the same asset and for a new one
+	out := filepath.Join(dir, outDir)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	for i, content := range []string{"pixel", "point"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, "img/dot.png"), []byte(content), 0644); err != nil {
+			t.Fatalf("can't change inlined file: %v\n", err)
+		}
+		if i > 0 {
+			a = New(filepath.Join(dir, "style.css"))
+			a.SetCompress(false)
+			a.SetInlineAssets(10)
+		}
+		f, err := a.Put(out, "")
+		if err != nil {
+			t.Fatalf("Put returned error: %v\n", err)
+		}
+		if f == fname {
+			t.Fatalf("Put didn't notice the change of inlined file to %q.", content)
+		}
+		fname = f
+	}
+}
+
+func TestInlineImports(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+
+	srcs := map[string]string{
+		"style.css":     "@import \"lib/reset.css\";\n@import url(print.css) print;\nbody { color: red; }",
+		"lib/reset.css": "@import url('base.css');\n* { margin: 0; }",
+		"lib/base.css":  "html { font-size: 16px; }",
+		"loop.css":      "@import \"lib/loop.css\";",
+		"lib/loop.css":  "@import '../loop.css';",
+	}
+	for name, content := range srcs {
+		fname := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("can't create test file \"%s\": %v\n", name, err)
+		}
+	}
+	a := New(filepath.Join(dir, "style.css"))
+	a.SetCompress(false)
+	a.SetInlineImports(true)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := "html { font-size: 16px; }\n* { margin: 0; }\n@import url(print.css) print;\nbody { color: red; }"
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+
+	a = New(filepath.Join(dir, "loop.css"))
+	a.SetInlineImports(true)
+	if _, _, err = a.Build(""); err == nil {
+		t.Fatalf("Build didn't return error of circular import.")
+	}
+}
+
+func TestURLBase(t *testing.T) {
+	a := New()
+	a.AddString(`a { background: url(../img/x.png); }
+b { background: url('fonts/y.woff?v=2#z'); }
+c { background: url("/img/z.png"), url(data:image/png;base64,AAAA), url(https://cdn.example.com/w.png); }`, ".css")
+	a.SetCompress(false)
+	a.SetURLBase("assets/style", "static")
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	expected := `a { background: url("../assets/img/x.png"); }
+b { background: url("../assets/style/fonts/y.woff?v=2#z"); }
+c { background: url("/img/z.png"), url(data:image/png;base64,AAAA), url(https://cdn.example.com/w.png); }`
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
@@ -0,0 +1,77 @@
+package assets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// ManifestFname is name of the manifest file. Assets that have manifest enabled
+// record names of their final files in a manifest file in their output directory.
+// It's a JSON object that maps a stable key, made of the name passed to Put and the
+// extension of the asset, like "app.js", to the current name of the final file, like
+// "app-d41d8cd98f00b204e9800998ecf8427e.js". Assets without name are keyed by just
+// their extension, like ".js".
+//
+// Assets writing to the same directory share the manifest. Each of them updates its
+// own key, and keys of files that are no longer in the directory are removed.
+const ManifestFname = "manifest.json"
+
+// writeManifest records final file of a in the manifest of its output directory, if
+// manifest is enabled for a.
+func (a *Asset) writeManifest() error {
+	if !a.manifest {
+		return nil
+	}
+	return updateManifest(a.dir, map[string]string{a.name + a.fileExt(): a.fname}, a.fileMode)
+}
+
+// dirLocks keeps a mutex for each output directory, keyed by its absolute path, so
+// that assets writing to the same directory don't step on each other.
+var dirLocks sync.Map
+
+// lockDir locks output directory dir, and returns the function that unlocks it.
+func lockDir(dir string) (unlock func()) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	m, _ := dirLocks.LoadOrStore(dir, new(sync.Mutex))
+	mu := m.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// updateManifest adds entries to the manifest file in dir, and removes entries of
+// files that don't exist anymore. A new manifest file is created with permissions
+// mode.
+func updateManifest(dir string, entries map[string]string, mode os.FileMode) error {
+	m := make(map[string]string)
+	buf, err := ioutil.ReadFile(path.Join(dir, ManifestFname))
+	if err == nil {
+		if err = json.Unmarshal(buf, &m); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for key, fname := range entries {
+		m[key] = fname
+	}
+	// prune stale entries
+	for key, fname := range m {
+		_, err := os.Stat(path.Join(dir, fname))
+		if os.IsNotExist(err) {
+			delete(m, key)
+		} else if err != nil {
+			return err
+		}
+	}
+	buf, err = json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, ManifestFname), buf, mode)
+}
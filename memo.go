@@ -0,0 +1,69 @@
+package assets
+
+import (
+	"os"
+	"path"
+)
+
+// memo is what an Asset remembers about its last Put, so that the next Put in the
+// same process can find the asset unchanged without reading the info file, or any of
+// the inputs. It only takes stats of the inputs, the files they import, and the info
+// file, which is changed by other processes that put the same asset.
+type memo struct {
+	dir, name string   // arguments of Put
+	info      stamp    // stamp of info file
+	settings  []string // settings of asset
+	stamps    []stamp  // stamps of inputs
+	deps      []stamp  // stamps of files imported by inputs
+	fname     string   // name of final file
+	sidecars  []string // names of files next to final file
+	files     []string // names of files of inputs, in dev mode
+}
+
+// remember records the result of the last Put of a, which has put final file of a in
+// its output directory, along with its info file.
+func (a *Asset) remember() {
+	fi, err := os.Stat(a.infoPath())
+	if err != nil {
+		a.memo = nil
+		return
+	}
+	a.memo = &memo{a.dir, a.name, fileStamp(a.infoPath(), fi), a.settings(),
+		a.inputStamps, a.depStamps, a.fname, a.sidecars, a.files}
+}
+
+// recall reports whether nothing has changed since the last Put of a, according to
+// what a remembers about it. If so, the final file of that Put is recorded as the old
+// one, like readInfo does. Stamps of the inputs are made in any case. CSS imports are
+// only known by reading the inputs, and changes of a transform function can't be
+// seen without it, so assets that inline imports or transform never recall anything.
+func (a *Asset) recall() (bool, error) {
+	var err error
+	if a.inputStamps, err = a.makeStamps(); err != nil {
+		return false, err
+	}
+	m := a.memo
+	if m == nil || a.imports || a.transform != nil || m.dir != a.dir || m.name != a.name ||
+		m.info.Fname != a.infoPath() || !equalStrings(m.settings, a.settings()) ||
+		!sameInputStamps(m.stamps, a.inputStamps) || !a.sameDepStamps(m.deps) {
+		return false, nil
+	}
+	// info file is not in the filesystem of the inputs
+	fi, err := os.Stat(m.info.Fname)
+	if err != nil ||
+		!sameInputStamps([]stamp{m.info}, []stamp{fileStamp(m.info.Fname, fi)}) {
+		return false, nil
+	}
+	// the final file may have been removed by someone else
+	if _, err = os.Stat(path.Join(a.dir, m.fname)); err != nil {
+		return false, nil
+	}
+	a.oldfname, a.oldsidecars, a.oldfiles = m.fname, m.sidecars, m.files
+	a.depStamps = m.deps
+	return true, nil
+}
+
+// fileStamp returns stamp of file fname, with its stat fi.
+func fileStamp(fname string, fi os.FileInfo) stamp {
+	return stamp{Fname: fname, ModTime: fi.ModTime(), Size: fi.Size()}
+}
@@ -0,0 +1,56 @@
+package assets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemo(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, outDir)
+
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	// empty info file without changing its stamp, so that only a Put that reads it
+	// would build again
+	infoPath := a.infoPath()
+	fi, err := os.Stat(infoPath)
+	if err != nil {
+		t.Fatalf("can't stat info: %v\n", err)
+	}
+	empty := append([]byte("{}"), bytes.Repeat([]byte(" "), int(fi.Size())-2)...)
+	if err = ioutil.WriteFile(infoPath, empty, 0644); err != nil {
+		t.Fatalf("can't write info: %v\n", err)
+	}
+	if err = os.Chtimes(infoPath, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatalf("can't change time of info: %v\n", err)
+	}
+	f, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if !a.cached || f != fname {
+		t.Fatalf("expected: %s from memory\ngot: %s, cached: %v\n", fname, f, a.cached)
+	}
+
+	// a new modification time of an input is a change
+	later := time.Now().Add(time.Minute)
+	if err = os.Chtimes(filepath.Join(dir, "c.js"), later, later); err != nil {
+		t.Fatalf("can't change time of input: %v\n", err)
+	}
+	if _, err = a.Put(out, ""); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if a.cached {
+		t.Fatalf("Put didn't notice the new modification time of the input.")
+	}
+}
@@ -0,0 +1,289 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+)
+
+// minifyCSS removes comments and unnecessary whitespace from CSS code. It doesn't
+// change anything else, so it's safe but not as effective as real compressors.
+func minifyCSS(in []byte) []byte {
+	out := make([]byte, 0, len(in))
+	space := false // is there whitespace before the current character?
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		switch {
+		case c == '/' && i+1 < len(in) && in[i+1] == '*':
+			end := bytes.Index(in[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			space = true
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			continue
+		}
+		// whitespace is needed only between other characters, and
+		// after a colon, only in selectors like "a :hover"
+		if space && len(out) > 0 &&
+			bytes.IndexByte([]byte("{};,>~:("), out[len(out)-1]) < 0 &&
+			bytes.IndexByte([]byte("{};,>~)!"), c) < 0 {
+			out = append(out, ' ')
+		}
+		space = false
+		switch c {
+		case '"', '\'':
+			// copy strings as they are
+			j := i + 1
+			for ; j < len(in) && in[j] != c; j++ {
+				if in[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(in) {
+				j = len(in) - 1
+			}
+			out = append(out, in[i:j+1]...)
+			i = j
+		case '}':
+			// last semicolon of a block is not needed
+			if len(out) > 0 && out[len(out)-1] == ';' {
+				out = out[:len(out)-1]
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// eof marks the end of input of jsMin.
+const eof = -1
+
+// jsMin removes comments and unnecessary whitespace from JS code. It's a port of
+// Douglas Crockford's JSMin, which is careful about strings, regular expression
+// literals, and newlines that end statements, but doesn't change anything else.
+type jsMin struct {
+	in        []byte
+	pos       int
+	out       bytes.Buffer
+	a, b      int
+	lookahead int
+	x, y      int
+}
+
+// minifyJS minifies JS code using jsMin.
+func minifyJS(in []byte) ([]byte, error) {
+	m := &jsMin{in: in, lookahead: eof, x: eof, y: eof}
+	// skip byte order mark
+	if bytes.HasPrefix(in, []byte("\xef\xbb\xbf")) {
+		m.pos = 3
+	}
+	if err := m.run(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(m.out.Bytes(), "\n"), nil
+}
+
+// isAlphanum reports whether c is a letter, digit, underscore, dollar sign, or
+// non-ASCII character.
+func isAlphanum(c int) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+		(c >= 'A' && c <= 'Z') || c == '_' || c == '$' || c == '\\' || c > 126
+}
+
+// get returns the next character of input, turning control characters into spaces
+// and carriage returns into newlines.
+func (m *jsMin) get() int {
+	c := m.lookahead
+	m.lookahead = eof
+	if c == eof && m.pos < len(m.in) {
+		c = int(m.in[m.pos])
+		m.pos++
+	}
+	if c >= ' ' || c == '\n' || c == eof {
+		return c
+	}
+	if c == '\r' {
+		return '\n'
+	}
+	return ' '
+}
+
+// peek returns the next character of input without consuming it.
+func (m *jsMin) peek() int {
+	m.lookahead = m.get()
+	return m.lookahead
+}
+
+// next returns the next character of input, skipping comments.
+func (m *jsMin) next() (int, error) {
+	c := m.get()
+	if c == '/' {
+		switch m.peek() {
+		case '/':
+			for c > '\n' {
+				c = m.get()
+			}
+		case '*':
+			m.get()
+			for c != ' ' {
+				switch m.get() {
+				case '*':
+					if m.peek() == '/' {
+						m.get()
+						c = ' '
+					}
+				case eof:
+					return 0, errors.New("assets: unterminated comment in JS")
+				}
+			}
+		}
+	}
+	m.y, m.x = m.x, c
+	return c, nil
+}
+
+// put writes c to the output.
+func (m *jsMin) put(c int) {
+	m.out.WriteByte(byte(c))
+}
+
+// action does one of these, depending on d:
+//
+//         1: output A, copy B to A, get the next B
+//         2: copy B to A, get the next B (delete A)
+//         3: get the next B (delete B)
+//
+// Strings and regular expression literals are copied as they are.
+func (m *jsMin) action(d int) (err error) {
+	if d <= 1 {
+		m.put(m.a)
+		if (m.y == '\n' || m.y == ' ') &&
+			(m.a == '+' || m.a == '-' || m.a == '*' || m.a == '/') &&
+			(m.b == '+' || m.b == '-' || m.b == '*' || m.b == '/') {
+			m.put(m.y)
+		}
+	}
+	if d <= 2 {
+		m.a = m.b
+		if m.a == '\'' || m.a == '"' || m.a == '`' {
+			for {
+				m.put(m.a)
+				m.a = m.get()
+				if m.a == m.b {
+					break
+				}
+				if m.a == '\\' {
+					m.put(m.a)
+					m.a = m.get()
+				}
+				if m.a == eof {
+					return errors.New("assets: unterminated string literal in JS")
+				}
+			}
+		}
+	}
+	if m.b, err = m.next(); err != nil {
+		return
+	}
+	if m.b == '/' && bytes.IndexByte([]byte("(,=:[!&|?+-~*/{};"), byte(m.a)) >= 0 {
+		m.put(m.a)
+		if m.a == '/' || m.a == '*' {
+			m.put(' ')
+		}
+		m.put(m.b)
+		for {
+			m.a = m.get()
+			if m.a == '[' {
+				for {
+					m.put(m.a)
+					m.a = m.get()
+					if m.a == ']' {
+						break
+					}
+					if m.a == '\\' {
+						m.put(m.a)
+						m.a = m.get()
+					}
+					if m.a == eof {
+						return errors.New("assets: unterminated set in JS regular expression")
+					}
+				}
+			} else if m.a == '/' {
+				if c := m.peek(); c == '/' || c == '*' {
+					return errors.New("assets: unterminated set in JS regular expression")
+				}
+				break
+			} else if m.a == '\\' {
+				m.put(m.a)
+				m.a = m.get()
+			}
+			if m.a == eof {
+				return errors.New("assets: unterminated JS regular expression")
+			}
+			m.put(m.a)
+		}
+		if m.b, err = m.next(); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// run minifies the whole input.
+func (m *jsMin) run() error {
+	m.a = '\n'
+	if err := m.action(3); err != nil {
+		return err
+	}
+	for m.a != eof {
+		var d int
+		switch m.a {
+		case ' ':
+			d = 2
+			if isAlphanum(m.b) {
+				d = 1
+			}
+		case '\n':
+			switch m.b {
+			case '{', '[', '(', '+', '-', '!', '~':
+				d = 1
+			case ' ':
+				d = 3
+			default:
+				d = 2
+				if isAlphanum(m.b) {
+					d = 1
+				}
+			}
+		default:
+			switch m.b {
+			case ' ':
+				d = 3
+				if isAlphanum(m.a) {
+					d = 1
+				}
+			case '\n':
+				switch m.a {
+				case '}', ']', ')', '+', '-', '"', '\'', '`':
+					d = 1
+				default:
+					d = 3
+					if isAlphanum(m.a) {
+						d = 1
+					}
+				}
+			default:
+				d = 1
+			}
+		}
+		if err := m.action(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
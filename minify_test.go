@@ -0,0 +1,74 @@
+package assets
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{files["a.css"], "body{color:red}"},
+		{"/* comment */\nb {\n  color : #444 ;\n}\n", "b{color :#444}"},
+		{"a > b,\ni ~ u { margin: 0 auto !important; }", "a>b,i~u{margin:0 auto!important}"},
+		{"a :hover { content: \"  a ; b  \"; }", "a :hover{content:\"  a ; b  \"}"},
+		{"@media screen and (max-width: 10px) { a { b: c } }",
+			"@media screen and (max-width:10px){a{b:c}}"},
+		{"a { width: calc(1px + 2px) }", "a{width:calc(1px + 2px)}"},
+	}
+	for _, test := range tests {
+		if out := string(minifyCSS([]byte(test.in))); out != test.out {
+			t.Errorf("expected: %s\ngot: %s\n", test.out, out)
+		}
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{files["c.js"], "window.c=function(){console.log(\"c\");};"},
+		{"// comment\nvar a = 1;  /* block */\nfunction f ( x ) {\n  return x + 1;\n}\n",
+			"var a=1;function f(x){return x+1;}"},
+		{"var s = 'a  //  b', r = /[/]  \\//g;", "var s='a  //  b',r=/[/]  \\//g;"},
+		{"a = b\n++c", "a=b\n++c"},
+		{"x = y + +z", "x=y+ +z"},
+	}
+	for _, test := range tests {
+		out, err := minifyJS([]byte(test.in))
+		if err != nil {
+			t.Errorf("minifyJS returned error: %v\n", err)
+		} else if string(out) != test.out {
+			t.Errorf("expected: %s\ngot: %s\n", test.out, string(out))
+		}
+	}
+	if _, err := minifyJS([]byte("var s = 'a")); err == nil {
+		t.Errorf("minifyJS accepted unterminated string.")
+	}
+}
+
+func TestCSSMinifyLevel(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["cleancss"] = fakeTool(t, dir, "cleancss", `echo "$@"`)
+	defer delete(Commands, "cleancss")
+
+	a := New()
+	a.SetCSSCompressor("cleancss")
+	if err := a.SetCSSMinifyLevel(3); err == nil {
+		t.Fatalf("SetCSSMinifyLevel accepted level 3")
+	}
+	for level, expected := range []string{"a b{color:red}", "-O1\n", "-O2\n"} {
+		if err := a.SetCSSMinifyLevel(level); err != nil {
+			t.Fatalf("SetCSSMinifyLevel returned error: %v\n", err)
+		}
+		out, err := a.runCSSCompress([]byte("a  b {\n\tcolor: red;\n}\n"))
+		if err != nil {
+			t.Fatalf("runCSSCompress returned error: %v\n", err)
+		}
+		if string(out) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, string(out))
+		}
+	}
+}
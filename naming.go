@@ -0,0 +1,40 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// defaultNameTemplate makes names like "app-d41d8cd98f00b204e9800998ecf8427e.js", or
+// "d41d8cd98f00b204e9800998ecf8427e.js" for assets without name.
+const defaultNameTemplate = "{{if .Name}}{{.Name}}-{{end}}{{.Hash}}{{.Ext}}"
+
+// nameData is what name templates are executed with.
+type nameData struct {
+	Name string // name passed to Put
+	Hash string // hash of content of final file
+	Ext  string // extension of final file, ".css" or ".js"
+}
+
+// makeName executes name template tmpl with data d, and checks that the result is a
+// file name in the output directory, maybe in subdirectories of it.
+func makeName(tmpl string, d nameData) (string, error) {
+	t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	name := buf.String()
+	clean := path.Clean(name)
+	if len(name) == 0 || strings.HasSuffix(name, "/") || path.IsAbs(clean) ||
+		clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("assets: invalid name %q made by name template", name)
+	}
+	return clean, nil
+}
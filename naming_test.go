@@ -0,0 +1,54 @@
+package assets
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func TestNameTemplate(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"))
+	a.SetCompress(false)
+	if err := a.SetNameTemplate("{{.Hash}}/{{.Name}}{{.Ext}}"); err != nil {
+		t.Fatalf("SetNameTemplate returned error: %v\n", err)
+	}
+	fname, err := a.Put(out, "app")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if expected := a.ContentHash() + "/app.js"; fname != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, fname)
+	}
+	if !exists(filepath.Join(out, fname)) {
+		t.Fatalf("Put didn't write \"%s\".", fname)
+	}
+	// old directory is removed with the old file
+	a.AddString("window.d = 4;", ".js")
+	if _, err = a.Put(out, "app"); err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if exists(filepath.Join(out, path.Dir(fname))) {
+		t.Fatalf("Put didn't remove old directory.")
+	}
+
+	if err = a.SetNameTemplate("{{.Name}}.{{.Hash}}{{.Ext}}"); err != nil {
+		t.Fatalf("SetNameTemplate returned error: %v\n", err)
+	}
+	if _, fname, err = a.Build("app"); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "app." + a.ContentHash() + ".js"; fname != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, fname)
+	}
+
+	for _, tmpl := range []string{"{{.Name", "{{.Size}}", "../{{.Name}}{{.Ext}}", "/{{.Hash}}", "{{.Name}}/"} {
+		if err = a.SetNameTemplate(tmpl); err == nil {
+			t.Fatalf("SetNameTemplate accepted %q", tmpl)
+		}
+	}
+}
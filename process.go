@@ -1,32 +1,298 @@
 package assets
 
 import (
+	"bytes"
+	"context"
 	"errors"
-	"github.com/mostafah/run"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
-func runLess(in []byte) (out []byte, err error) {
-	return runCmd(in, "lessc", "-")
+// Commands maps names of the external tools, like "lessc", "coffee", "sassc", or
+// "yuicompressor", to the commands that should be run instead of them. It's empty by
+// default, which means that the tools are looked up in PATH. Add an entry if a tool
+// lives somewhere else:
+//
+//         assets.Commands["lessc"] = "node_modules/.bin/lessc"
+var Commands = map[string]string{}
+
+// runLess compiles LESS code. If source maps are enabled, source map is embedded in
+// the output. Variables of a are passed to lessc, to override the ones in the code.
+func (a *Asset) runLess(in []byte) (out []byte, err error) {
+	var args []string
+	for _, v := range a.variables {
+		args = append(args, "--modify-var="+v)
+	}
+	if a.sourceMaps {
+		args = append(args, "--source-map-map-inline")
+	}
+	args = append(args, a.toolArgs["less"]...)
+	return a.runCompiler(in, "lessc", append(args, "-")...)
 }
 
-func runCoffee(in []byte) (out []byte, err error) {
-	return runCmd(in, "coffee", "-sc")
+// runSass compiles Sass code. Variables of a are declared before the code, so that
+// the code can use them.
+func (a *Asset) runSass(in []byte) (out []byte, err error) {
+	return a.runCompiler(append(a.sassVariables(";"), in...), "sassc", a.sassArgs()...)
 }
 
-func runCSSCompress(in []byte) (out []byte, err error) {
-	return runCmd(in, "yuicompressor", "--type", "css")
+// runSassIndented compiles Sass files written in the indented syntax.
+func (a *Asset) runSassIndented(in []byte) (out []byte, err error) {
+	return a.runCompiler(append(a.sassVariables(""), in...), "sassc",
+		append(a.sassArgs(), "--sass")...)
 }
 
-func runJSCompress(in []byte) (out []byte, err error) {
-	return runCmd(in, "yuicompressor", "--type", "js")
+// sassArgs returns arguments of sassc, with the output style of a if it's set.
+func (a *Asset) sassArgs() []string {
+	if len(a.sassStyle) > 0 {
+		return []string{"--stdin", "--style", a.sassStyle}
+	}
+	return []string{"--stdin"}
 }
 
-func runCmd(in []byte, cmd string, args ...string) (out []byte, err error) {
-	stdout, stderr, err := run.Run(in, cmd, args...)
-	if len(stderr) != 0 {
-		return nil, errors.New("stderr: " + string(stderr))
-	} else if err != nil {
+// sassVariables returns declarations of variables of a in Sass, each ending in end
+// and a newline.
+func (a *Asset) sassVariables(end string) []byte {
+	var b []byte
+	for _, v := range a.variables {
+		i := strings.Index(v, "=")
+		b = append(b, "$"+v[:i]+": "+v[i+1:]+end+"\n"...)
+	}
+	return b
+}
+
+// runStylus compiles Stylus code.
+func (a *Asset) runStylus(in []byte) (out []byte, err error) {
+	return a.runCompiler(in, "stylus")
+}
+
+// runCoffee compiles CoffeeScript code. If source maps are enabled, source map is
+// embedded in the output. The output is not wrapped in a function if a is bare.
+func (a *Asset) runCoffee(in []byte) (out []byte, err error) {
+	args := []string{"-sc"}
+	if a.coffeeBare {
+		args = append(args, "--bare")
+	}
+	if a.sourceMaps {
+		args = append(args, "--inline-map")
+	}
+	return a.runCompiler(in, "coffee", append(args, a.toolArgs["coffee"]...)...)
+}
+
+// runTypeScript compiles TypeScript code into JS. tsc can't read its input from
+// stdin, so the code is written into a file in a temporary directory and compiled
+// there, and the resulting .js file is read back. The temporary directory is removed
+// afterwards.
+func (a *Asset) runTypeScript(in []byte) (out []byte, err error) {
+	dir, err := ioutil.TempDir("", "assets-tsc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "input.ts")
+	if err = ioutil.WriteFile(src, in, 0600); err != nil {
+		return nil, err
+	}
+	if _, err = a.runCmd(nil, "tsc", "--outDir", dir, src); err != nil {
 		return nil, err
 	}
-	return stdout, nil
+	return ioutil.ReadFile(filepath.Join(dir, "input.js"))
+}
+
+// runJSX compiles JSX code into JS using esbuild. If source maps are enabled, source
+// map is embedded in the output.
+func (a *Asset) runJSX(in []byte) (out []byte, err error) {
+	if a.sourceMaps {
+		return a.runCompiler(in, "esbuild", "--loader=jsx", "--sourcemap=inline")
+	}
+	return a.runCompiler(in, "esbuild", "--loader=jsx")
+}
+
+// runBabel transpiles modern JS code using babel.
+func (a *Asset) runBabel(in []byte) (out []byte, err error) {
+	return a.runCompiler(in, "babel")
+}
+
+// runAutoprefix adds vendor prefixes to CSS code using the autoprefixer plugin of
+// PostCSS.
+func (a *Asset) runAutoprefix(in []byte) (out []byte, err error) {
+	return a.runCmd(in, "postcss", "--use", "autoprefixer")
+}
+
+// nativeCompressor is the name of the compressors that are built into this package,
+// and don't need any external tool.
+const nativeCompressor = "native"
+
+// cssCompressors keeps commands and arguments of known CSS compressors.
+var cssCompressors = map[string][]string{
+	"yuicompressor":  {"yuicompressor", "--type", "css"},
+	"cleancss":       {"cleancss"},
+	nativeCompressor: {nativeCompressor},
+}
+
+// cssLevelArgs keeps arguments of CSS compressors for each minification level, for
+// the compressors that have levels. Other compressors make the same output for levels
+// 1 and 2.
+var cssLevelArgs = map[string][]string{
+	"cleancss": {"-O0", "-O1", "-O2"},
+}
+
+// runCSSCompress compresses CSS code using the CSS compressor of a. Level 0 only
+// needs whitespace and comments removed, which the native compressor does safely.
+func (a *Asset) runCSSCompress(in []byte) (out []byte, err error) {
+	if a.cssCompressor[0] == nativeCompressor || a.cssLevel == 0 {
+		return minifyCSS(in), nil
+	}
+	args := append(a.cssCompressor[1:len(a.cssCompressor):len(a.cssCompressor)],
+		a.toolArgs["csscompress"]...)
+	if levels, ok := cssLevelArgs[a.cssCompressor[0]]; ok {
+		args = append(args, levels[a.cssLevel])
+	}
+	return a.runCmd(in, a.cssCompressor[0], args...)
+}
+
+// jsCompressors keeps commands and arguments of known JS compressors.
+var jsCompressors = map[string][]string{
+	"yuicompressor":  {"yuicompressor", "--type", "js"},
+	"terser":         {"terser", "--compress", "--mangle"},
+	"uglifyjs":       {"uglifyjs", "--compress", "--mangle"},
+	nativeCompressor: {nativeCompressor},
+}
+
+// runJSCompress compresses JS code using the JS compressor of a.
+func (a *Asset) runJSCompress(in []byte) (out []byte, err error) {
+	if a.jsCompressor[0] == nativeCompressor {
+		return minifyJS(in)
+	}
+	args := append(a.jsCompressor[1:len(a.jsCompressor):len(a.jsCompressor)],
+		a.toolArgs["jscompress"]...)
+	return a.runCmd(in, a.jsCompressor[0], args...)
+}
+
+// CmdError is the error of an external tool that fails, or takes too long. Use
+// errors.As to get it from errors of Put or Build.
+type CmdError struct {
+	Tool   string   // name of the tool, like "lessc"
+	Args   []string // arguments passed to the tool
+	Stderr []byte   // what the tool wrote to stderr
+	Err    error    // why the tool failed, like its exit status
+	Input  string   // name of the input the tool failed on, if it's known
+}
+
+func (e *CmdError) Error() string {
+	msg := "assets: " + e.Tool + ": "
+	if len(e.Input) > 0 {
+		msg += e.Input + ": "
+	}
+	msg += e.Err.Error()
+	if stderr := bytes.TrimSpace(e.Stderr); len(stderr) > 0 {
+		msg += "\n" + string(stderr)
+	}
+	return msg
+}
+
+func (e *CmdError) Unwrap() error {
+	return e.Err
+}
+
+// Runner runs the external tools instead of the operating system, if it's not nil.
+// It gets the input of the tool, and the command and its arguments, after the
+// command is looked up in Commands, and returns what the tool writes to stdout and
+// stderr, and whether it fails. It's nil by default, and is meant for tests that
+// shouldn't depend on the tools being installed. Timeouts don't apply to Runner.
+var Runner func(in []byte, cmd string, args ...string) (stdout, stderr []byte, err error)
+
+// runCmd runs cmd with args, feeds it with in, and returns its output. Failure is
+// decided by the exit status of cmd. Tools often write warnings to stderr while
+// succeeding, so stderr is only logged, unless cmd fails. If a has a timeout, cmd is
+// killed when it takes longer. Failures are returned as *CmdError. cmd is run by
+// Runner if it's set.
+func (a *Asset) runCmd(in []byte, cmd string, args ...string) (out []byte, err error) {
+	tool := cmd
+	if c, ok := Commands[cmd]; ok {
+		cmd = c
+	}
+	var stderr []byte
+	if Runner != nil {
+		out, stderr, err = Runner(in, cmd, args...)
+	} else {
+		out, stderr, err = a.execCmd(in, cmd, args...)
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("assets: required tool %q not found in PATH; "+
+			"install it or set its path in Commands", cmd)
+	}
+	if err != nil {
+		return nil, &CmdError{Tool: tool, Args: args, Stderr: stderr, Err: err}
+	}
+	if len(stderr) != 0 {
+		a.logf("%s: %s", cmd, bytes.TrimSpace(stderr))
+	}
+	return out, nil
+}
+
+// killWaitDelay is how long execCmd waits for output of a tool after killing it.
+const killWaitDelay = 100 * time.Millisecond
+
+// execCmd runs cmd with args as a process, feeds it with in, and returns what it
+// writes to stdout and stderr. If a has a timeout, cmd is killed when it takes longer.
+func (a *Asset) execCmd(in []byte, cmd string, args ...string) (stdout, stderr []byte, err error) {
+	ctx := context.Background()
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+	var outBuf, errBuf bytes.Buffer
+	c := exec.CommandContext(ctx, cmd, args...)
+	if len(a.toolEnv) > 0 {
+		// later variables override the earlier ones with the same name
+		c.Env = append(os.Environ(), a.toolEnv...)
+		p, err := a.lookPath(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		// cmd may not be found in PATH of the program
+		c.Path, c.Err = p, nil
+	}
+	// processes started by cmd, like by a shell or node wrapper, are not killed with
+	// it, and may keep its output open, so it's not waited for after the timeout
+	c.WaitDelay = killWaitDelay
+	c.Stdin = bytes.NewReader(in)
+	c.Stdout, c.Stderr = &outBuf, &errBuf
+	err = c.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v: %w", a.timeout, ctx.Err())
+	}
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// lookPath finds cmd like exec.LookPath, but in PATH of the tool environment of a, if
+// it has one.
+func (a *Asset) lookPath(cmd string) (string, error) {
+	dirs := ""
+	found := false
+	for _, v := range a.toolEnv {
+		if strings.HasPrefix(v, "PATH=") {
+			dirs, found = v[len("PATH="):], true
+		}
+	}
+	if !found || strings.Contains(cmd, "/") {
+		return exec.LookPath(cmd)
+	}
+	for _, dir := range filepath.SplitList(dirs) {
+		if len(dir) == 0 {
+			dir = "."
+		}
+		p := filepath.Join(dir, cmd)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return p, nil
+		}
+	}
+	return "", &exec.Error{Name: cmd, Err: exec.ErrNotFound}
 }
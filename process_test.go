@@ -0,0 +1,349 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCmdWarnings(t *testing.T) {
+	var buf bytes.Buffer
+	a := New()
+	a.SetLogger(log.New(&buf, "", 0))
+	out, err := a.runCmd([]byte("body{}"), "sh", "-c", "echo deprecated >&2; cat")
+	if err != nil {
+		t.Fatalf("runCmd returned error: %v\n", err)
+	}
+	if string(out) != "body{}" {
+		t.Fatalf("expected: body{}\ngot: %s\n", string(out))
+	}
+	if expected := "assets: sh: deprecated\n"; buf.String() != expected {
+		t.Fatalf("expected log: %s\ngot: %s\n", expected, buf.String())
+	}
+
+	// failure is still an error
+	_, err = a.runCmd(nil, "sh", "-c", "echo broken >&2; exit 1")
+	var cmdErr *CmdError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected CmdError\ngot: %v\n", err)
+	}
+	if cmdErr.Tool != "sh" || string(cmdErr.Stderr) != "broken\n" || cmdErr.Err == nil {
+		t.Fatalf("unexpected CmdError: %#v\n", cmdErr)
+	}
+	if expected := "assets: sh: exit status 1\nbroken"; err.Error() != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, err.Error())
+	}
+}
+
+func TestMissingTool(t *testing.T) {
+	_, err := New().runCmd(nil, "assets-missing-tool")
+	expected := `assets: required tool "assets-missing-tool" not found in PATH; ` +
+		"install it or set its path in Commands"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error: %s\ngot: %v\n", expected, err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	// a wrapper that doesn't exec the tool, which keeps stdout open after it's killed
+	Commands["lessc"] = fakeTool(t, dir, "lessc", "sleep 5\necho done")
+	defer delete(Commands, "lessc")
+
+	a := New()
+	a.SetTimeout(100 * time.Millisecond)
+	start := time.Now()
+	_, err := a.runLess([]byte("a {}"))
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error\ngot: %v\n", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("runCmd didn't stop the tool in time, it took %v", d)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["lessc"] = fakeTool(t, dir, "lessc", `echo "$@"`)
+	defer delete(Commands, "lessc")
+	Commands["sassc"] = fakeTool(t, dir, "sassc", "cat")
+	defer delete(Commands, "sassc")
+
+	a := New()
+	a.SetVariables(map[string]string{"color": "#336699", "size": "10px"})
+	out, err := a.runLess(nil)
+	if err != nil {
+		t.Fatalf("runLess returned error: %v\n", err)
+	}
+	if expected := "--modify-var=color=#336699 --modify-var=size=10px -\n"; string(out) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(out))
+	}
+	out, err = a.runSass([]byte("a { color: $color; }"))
+	if err != nil {
+		t.Fatalf("runSass returned error: %v\n", err)
+	}
+	if expected := "$color: #336699;\n$size: 10px;\na { color: $color; }"; string(out) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(out))
+	}
+
+	// different variables make different files
+	build := func(color string) string {
+		a := New()
+		a.AddString("a { color: $color; }", ".scss")
+		a.SetCompress(false)
+		a.SetVariables(map[string]string{"color": color})
+		_, fname, err := a.Build("")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		return fname
+	}
+	if build("red") == build("blue") {
+		t.Fatalf("variables didn't change the final file")
+	}
+}
+
+func TestJSX(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	// fake esbuild prints its arguments before the code
+	Commands["esbuild"] = fakeTool(t, dir, "esbuild", `echo "// $@"; cat`)
+	defer delete(Commands, "esbuild")
+
+	a := New()
+	a.AddString("const a = <A/>;", ".jsx")
+	a.AddString("const b = <B/>;", ".jsx")
+	a.AddString("var c;", ".js")
+	a.SetCompress(false)
+	b, fname, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if !strings.HasSuffix(fname, ".js") {
+		t.Fatalf("expected a .js file\ngot: %s\n", fname)
+	}
+	// both JSX inputs are compiled in one run
+	expected := "// --loader=jsx\nconst a = <A/>;\nconst b = <B/>;\nvar c;"
+	if string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+}
+
+func TestToolArgs(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["lessc"] = fakeTool(t, dir, "lessc", `echo "$@"`)
+	defer delete(Commands, "lessc")
+	Commands["yuicompressor"] = fakeTool(t, dir, "yuicompressor", `echo "$@"`)
+	defer delete(Commands, "yuicompressor")
+
+	a := New()
+	if err := a.SetToolArgs("sass", "--precision=3"); err == nil {
+		t.Fatalf("SetToolArgs accepted an unknown tool")
+	}
+	if err := a.SetToolArgs("less", "--math=strict"); err != nil {
+		t.Fatalf("SetToolArgs returned error: %v\n", err)
+	}
+	if err := a.SetToolArgs("csscompress", "--line-break", "80"); err != nil {
+		t.Fatalf("SetToolArgs returned error: %v\n", err)
+	}
+	out, err := a.runLess(nil)
+	if err != nil {
+		t.Fatalf("runLess returned error: %v\n", err)
+	}
+	if expected := "--math=strict -\n"; string(out) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(out))
+	}
+	out, err = a.runCSSCompress(nil)
+	if err != nil {
+		t.Fatalf("runCSSCompress returned error: %v\n", err)
+	}
+	if expected := "--type css --line-break 80\n"; string(out) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(out))
+	}
+	// the preset of the compressor is not changed
+	if len(cssCompressors["yuicompressor"]) != 3 {
+		t.Fatalf("arguments are added to the preset: %q\n", cssCompressors["yuicompressor"])
+	}
+
+	// different arguments make different hashes, so that the asset is rebuilt
+	hashes := func(args ...string) string {
+		a := New()
+		a.AddString("a {}", ".css")
+		a.SetCompress(false)
+		a.SetToolArgs("less", args...)
+		if _, _, err := a.Build(""); err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		return strings.Join(a.hashes, " ")
+	}
+	if hashes() == hashes("--math=strict") {
+		t.Fatalf("tool arguments didn't change the hashes")
+	}
+}
+
+func TestSassStyle(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["sassc"] = fakeTool(t, dir, "sassc", `echo "/* $@ */"`)
+	defer delete(Commands, "sassc")
+
+	a := New()
+	a.AddString("a { b: c; }", ".scss")
+	if err := a.SetSassStyle("tiny"); err == nil {
+		t.Fatalf("SetSassStyle accepted an unknown style")
+	}
+	if err := a.SetSassStyle("compressed"); err != nil {
+		t.Fatalf("SetSassStyle returned error: %v\n", err)
+	}
+	// yuicompressor is not needed anymore
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "/* --stdin --style compressed */\n"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	// other CSS inputs are still compressed
+	a.AddString("i { u: v; }", ".css")
+	a.SetCSSCompressor("native")
+	if b, _, err = a.Build(""); err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if strings.Contains(string(b), "i { u: v; }") {
+		t.Fatalf("expected: compressed CSS input\ngot: %s\n", b)
+	}
+}
+
+func TestCoffeeBare(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["coffee"] = fakeTool(t, dir, "coffee", `echo "// $@"`)
+	defer delete(Commands, "coffee")
+
+	build := func(bare bool) (b []byte, fname string) {
+		a := New()
+		a.AddString("x = 1", ".coffee")
+		a.SetCompress(false)
+		a.SetCoffeeBare(bare)
+		b, fname, err := a.Build("")
+		if err != nil {
+			t.Fatalf("Build returned error: %v\n", err)
+		}
+		return b, fname
+	}
+	b, wrapped := build(false)
+	if expected := "// -sc\n"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	b, bare := build(true)
+	if expected := "// -sc --bare\n"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	if bare == wrapped {
+		t.Fatalf("expected: different names\ngot: %s for both\n", bare)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	var ran []string
+	Runner = func(in []byte, cmd string, args ...string) (stdout, stderr []byte, err error) {
+		ran = append(ran, cmd+" "+strings.Join(args, " "))
+		if cmd == "lessc" {
+			return bytes.ToUpper(in), []byte("a warning\n"), nil
+		}
+		return nil, []byte("broken"), errors.New("exit status 1")
+	}
+	defer func() { Runner = nil }()
+
+	var buf bytes.Buffer
+	a := New()
+	a.AddString("b { c: d; }", ".less")
+	a.SetLogger(log.New(&buf, "", 0))
+	a.SetCompress(false)
+	b, _, err := a.Build("")
+	if err != nil {
+		t.Fatalf("Build returned error: %v\n", err)
+	}
+	if expected := "B { C: D; }"; string(b) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(b))
+	}
+	if !strings.Contains(buf.String(), "assets: lessc: a warning") {
+		t.Fatalf("stderr of Runner is not logged: %s\n", buf.String())
+	}
+	// failures of Runner are failures of the tools
+	a.SetCompress(true)
+	_, _, err = a.Build("")
+	var cmdErr *CmdError
+	if !errors.As(err, &cmdErr) || cmdErr.Tool != "yuicompressor" || string(cmdErr.Stderr) != "broken" {
+		t.Fatalf("expected CmdError of yuicompressor\ngot: %v\n", err)
+	}
+	if expected := []string{"lessc -", "lessc -", "yuicompressor --type css"}; !reflect.DeepEqual(ran, expected) {
+		t.Fatalf("expected: %q\ngot: %q\n", expected, ran)
+	}
+}
+
+func TestBlameJoinedInput(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	Commands["lessc"] = fakeTool(t, dir, "lessc",
+		`in=$(cat); case "$in" in *broken*) echo "error on line 2" >&2; exit 1;; esac; echo "$in"`)
+	defer delete(Commands, "lessc")
+
+	var fnames []string
+	for _, name := range []string{"a.less", "b.less", "c.less"} {
+		fname := filepath.Join(dir, name)
+		content := "a { color: red; }\n"
+		if name == "b.less" {
+			content = "b {\n  broken\n"
+		}
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v\n", err)
+		}
+		fnames = append(fnames, fname)
+	}
+	a := New(fnames...)
+	a.SetCompress(false)
+	_, _, err := a.Build("")
+	var cmdErr *CmdError
+	if !errors.As(err, &cmdErr) || cmdErr.Input != fnames[1] {
+		t.Fatalf("expected error of %s\ngot: %v\n", fnames[1], err)
+	}
+	if expected := "assets: lessc: " + fnames[1] + ": exit status 1\nerror on line 2"; err.Error() != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, err.Error())
+	}
+}
+
+func TestToolEnv(t *testing.T) {
+	dir := tempFiles(t)
+	defer os.RemoveAll(dir)
+	// the tool is only in PATH of the tool environment
+	fakeTool(t, dir, "assets-env-tool", `echo "$ASSETS_GREETING $HOME"`)
+
+	a := New()
+	a.SetToolEnv(map[string]string{"PATH": dir, "ASSETS_GREETING": "hello"})
+	out, err := a.runCmd(nil, "assets-env-tool")
+	if err != nil {
+		t.Fatalf("runCmd returned error: %v\n", err)
+	}
+	// environment of the program is kept
+	if expected := "hello " + os.Getenv("HOME") + "\n"; string(out) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(out))
+	}
+	if os.Getenv("ASSETS_GREETING") != "" {
+		t.Fatalf("environment of the program is changed")
+	}
+	if _, err = New().runCmd(nil, "assets-env-tool"); err == nil {
+		t.Fatalf("tool is found without the tool environment")
+	}
+}
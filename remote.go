@@ -0,0 +1,43 @@
+package assets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// HTTPTimeout is how long fetching of an input from an HTTP or HTTPS URL may take.
+var HTTPTimeout = 30 * time.Second
+
+// isURL reports whether name of an input is an HTTP or HTTPS URL, instead of a file
+// name.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// urlExt returns extension of the path of URL u, ignoring its query.
+func urlExt(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return path.Ext(u)
+	}
+	return path.Ext(p.Path)
+}
+
+// fetch downloads the input at URL u.
+func fetch(u string) ([]byte, error) {
+	client := &http.Client{Timeout: HTTPTimeout}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("assets: can't fetch %s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
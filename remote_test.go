@@ -0,0 +1,49 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemote(t *testing.T) {
+	dir := tempFiles(t, "c.js")
+	defer os.RemoveAll(dir)
+
+	content := "window.r = 1;"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lib.js" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(dir, outDir)
+	a := New(filepath.Join(dir, "c.js"), srv.URL+"/lib.js?v=1")
+	a.SetCompress(false)
+	fname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if expected := files["c.js"] + "\n" + content; string(a.Bytes()) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, string(a.Bytes()))
+	}
+	// changes of the remote file are noticed
+	content = "window.r = 2;"
+	newFname, err := a.Put(out, "")
+	if err != nil {
+		t.Fatalf("Put returned error: %v\n", err)
+	}
+	if newFname == fname {
+		t.Fatalf("Put didn't notice the change of remote file.")
+	}
+
+	a = New(srv.URL + "/missing.js")
+	if _, _, err = a.Build(""); err == nil {
+		t.Fatalf("Build didn't return error of missing remote file.")
+	}
+}
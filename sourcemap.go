@@ -0,0 +1,93 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+)
+
+// inlineMapRe matches the comment that compilers append to their output when they
+// embed the source map in it.
+var inlineMapRe = regexp.MustCompile(`(?:/\*|//)# sourceMappingURL=data:application/json;(?:charset=[^;,]+;)?base64,([A-Za-z0-9+/=]+)(?: ?\*/)?\n?`)
+
+// extractSourceMap removes the inline source map from compiled code b and returns
+// the code without it and the decoded source map m. m is nil if b doesn't contain an
+// inline source map.
+func extractSourceMap(b []byte) (code, m []byte, err error) {
+	match := inlineMapRe.FindSubmatchIndex(b)
+	if match == nil {
+		return b, nil, nil
+	}
+	m, err = base64.StdEncoding.DecodeString(string(b[match[2]:match[3]]))
+	if err != nil {
+		return nil, nil, err
+	}
+	code = append(b[:match[0]:match[0]], b[match[1]:]...)
+	return code, m, nil
+}
+
+// mapCommentRe matches the comments that link compiled code to its source map.
+var mapCommentRe = regexp.MustCompile(`(?m)^(?:/\*|//)# sourceMappingURL=\S+?(?: ?\*/)?[ \t]*(?:\n|$)`)
+
+// removeSourceMapComment removes the comments that link compiled code b to its source
+// map, since the source map of the final file is linked instead.
+func removeSourceMapComment(b []byte) []byte {
+	return mapCommentRe.ReplaceAll(b, nil)
+}
+
+// sourceMapSection is a section of an index source map. It places the source map of
+// an input at the line and column where the input starts in the output.
+type sourceMapSection struct {
+	Offset struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"offset"`
+	Map json.RawMessage `json:"map"`
+}
+
+// joinSourceMaps makes an index source map of the inputs for output file fname.
+// Inputs are assumed to be joined in order, with sep between each two of them, and
+// to come after prefix.
+func joinSourceMaps(inputs []input, prefix, sep []byte, fname string) ([]byte, error) {
+	var sections []sourceMapSection
+	line, column := 0, 0
+	// advance moves the offset to the end of b
+	advance := func(b []byte) {
+		if n := bytes.Count(b, []byte("\n")); n > 0 {
+			line += n
+			column = len(b) - bytes.LastIndex(b, []byte("\n")) - 1
+		} else {
+			column += len(b)
+		}
+	}
+	advance(prefix)
+	for i, inp := range inputs {
+		if i > 0 {
+			advance(sep)
+		}
+		if inp.sourceMap != nil {
+			s := sourceMapSection{Map: inp.sourceMap}
+			s.Offset.Line, s.Offset.Column = line, column
+			sections = append(sections, s)
+		}
+		advance(inp.bytes)
+	}
+	if sections == nil {
+		return nil, nil
+	}
+	return json.Marshal(struct {
+		Version  int                `json:"version"`
+		File     string             `json:"file"`
+		Sections []sourceMapSection `json:"sections"`
+	}{3, fname, sections})
+}
+
+// sourceMapComment returns the comment that links an output with extension ext to
+// its source map.
+func sourceMapComment(ext, mapFname string) []byte {
+	if ext == ".css" {
+		return []byte("\n/*# sourceMappingURL=" + mapFname + " */\n")
+	}
+	return []byte("\n//# sourceMappingURL=" + mapFname + "\n")
+}
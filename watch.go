@@ -0,0 +1,122 @@
+package assets
+
+import (
+	"os"
+	"time"
+)
+
+// WatchInterval is how often watchers check input files for changes.
+var WatchInterval = time.Second
+
+// Watcher rebuilds an asset whenever its input files change. It's made by Watch.
+type Watcher struct {
+	// Names receives name of the final file after each rebuild.
+	Names <-chan string
+	// Errors receives errors of rebuilds. Watcher keeps watching after errors, so
+	// fixing the input files is enough to get a new file.
+	Errors <-chan error
+	done   chan struct{}
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() {
+	close(w.done)
+}
+
+// Watch puts the asset in dir, just like Put, and then keeps checking the input files
+// for changes, once every WatchInterval. Globs are expanded again on each check, so
+// new files that match them are noticed too. Inputs from URLs are not watched. When
+// something changes, Watch waits for the changes to settle down, puts the asset
+// again, and sends name of the final file to Names of the returned Watcher, or the
+// error to its Errors.
+//
+// Watch is meant for development, where you'd like to edit the input files and see
+// the results without restarting the program. The asset should not be used by other
+// goroutines until the watcher is closed, except by its Handler.
+func (a *Asset) Watch(dir, name string) (*Watcher, error) {
+	if _, err := a.Put(dir, name); err != nil {
+		return nil, err
+	}
+	stamps, err := a.stamps()
+	if err != nil {
+		return nil, err
+	}
+	names := make(chan string)
+	errs := make(chan error)
+	w := &Watcher{Names: names, Errors: errs, done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+		pending := false
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+			s, err := a.stamps()
+			if err == nil && !sameStamps(s, stamps) {
+				// wait for another tick to coalesce rapid changes
+				stamps, pending = s, true
+				continue
+			}
+			if !pending {
+				continue
+			}
+			pending = false
+			if err == nil {
+				var fname string
+				if fname, err = a.Put(dir, name); err == nil {
+					select {
+					case names <- fname:
+					case <-w.done:
+						return
+					}
+					continue
+				}
+			}
+			select {
+			case errs <- err:
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
+// stamps returns modification times of the input files, keyed by their names.
+// Missing files have zero time.
+func (a *Asset) stamps() (map[string]time.Time, error) {
+	if err := a.expandGlobs(); err != nil {
+		return nil, err
+	}
+	m := make(map[string]time.Time)
+	for _, inp := range a.inputs {
+		if len(inp.fname) == 0 || isURL(inp.fname) {
+			continue
+		}
+		info, err := a.statFile(inp.fname)
+		if err == nil {
+			m[inp.fname] = info.ModTime()
+		} else if os.IsNotExist(err) {
+			m[inp.fname] = time.Time{}
+		} else {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// sameStamps reports whether a and b have the same files and modification times.
+func sameStamps(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for fname, t := range a {
+		if u, ok := b[fname]; !ok || !t.Equal(u) {
+			return false
+		}
+	}
+	return true
+}